@@ -1,22 +1,44 @@
 package main
 
 import (
+	"context"
 	"fmt"
 	"log"
 	"os"
+	"path"
 	"runtime"
+	"time"
 
 	"github.com/effxhq/vcs-connect/internal/controller"
 	"github.com/effxhq/vcs-connect/internal/effx"
+	"github.com/effxhq/vcs-connect/internal/filter"
+	"github.com/effxhq/vcs-connect/internal/integrations"
+	"github.com/effxhq/vcs-connect/internal/integrations/azuredevops"
+	"github.com/effxhq/vcs-connect/internal/integrations/bitbucket"
+	"github.com/effxhq/vcs-connect/internal/integrations/bitbucketserver"
+	"github.com/effxhq/vcs-connect/internal/integrations/gitea"
 	"github.com/effxhq/vcs-connect/internal/integrations/github"
 	"github.com/effxhq/vcs-connect/internal/integrations/gitlab"
+	"github.com/effxhq/vcs-connect/internal/integrations/list"
+	"github.com/effxhq/vcs-connect/internal/integrations/local"
+	"github.com/effxhq/vcs-connect/internal/integrations/webhook"
+	"github.com/effxhq/vcs-connect/internal/mapping"
+	"github.com/effxhq/vcs-connect/internal/metrics"
+	"github.com/effxhq/vcs-connect/internal/mirror"
+	"github.com/effxhq/vcs-connect/internal/report"
 	"github.com/effxhq/vcs-connect/internal/run"
+	"github.com/effxhq/vcs-connect/internal/sshauth"
+	"github.com/effxhq/vcs-connect/internal/state"
+	"github.com/effxhq/vcs-connect/internal/synccache"
+	"github.com/effxhq/vcs-connect/internal/tracing"
 	"github.com/effxhq/vcs-connect/internal/v"
 
 	"github.com/pkg/errors"
 
 	"github.com/urfave/cli/v2"
 
+	"go.uber.org/multierr"
+
 	"gopkg.in/src-d/go-git.v4/plumbing/transport"
 	"gopkg.in/src-d/go-git.v4/plumbing/transport/http"
 )
@@ -26,7 +48,11 @@ var version string
 var commit string
 var date string
 
-func initAuthForGitHub(cfg *github.Configuration) transport.AuthMethod {
+func initAuthForGitHub(cfg *github.Configuration, integration *github.Integration) transport.AuthMethod {
+	if cfg.IsApp() {
+		return integration.AuthMethod()
+	}
+
 	return &http.BasicAuth{
 		Username: cfg.UserName,
 		Password: cfg.PersonalAccessToken,
@@ -40,37 +66,269 @@ func initAuthForGitLab(cfg *gitlab.Configuration) transport.AuthMethod {
 	}
 }
 
+func initAuthForBitbucket(cfg *bitbucket.Configuration) transport.AuthMethod {
+	return &http.BasicAuth{
+		Username: cfg.UserName,
+		Password: cfg.AppPassword,
+	}
+}
+
+func initAuthForBitbucketServer(cfg *bitbucketserver.Configuration) transport.AuthMethod {
+	return &http.BasicAuth{
+		Username: cfg.UserName,
+		Password: cfg.AccessToken,
+	}
+}
+
+func initAuthForAzureDevOps(cfg *azuredevops.Configuration) transport.AuthMethod {
+	return &http.BasicAuth{
+		Username: "vcs-connect",
+		Password: cfg.PersonalAccessToken,
+	}
+}
+
+func initAuthForGitea(cfg *gitea.Configuration) transport.AuthMethod {
+	return &http.BasicAuth{
+		Username: cfg.UserName,
+		Password: cfg.AccessToken,
+	}
+}
+
+func initAuthForList(cfg *list.Configuration) transport.AuthMethod {
+	if cfg.UserName == "" && cfg.Password == "" {
+		return nil
+	}
+
+	return &http.BasicAuth{
+		Username: cfg.UserName,
+		Password: cfg.Password,
+	}
+}
+
+func initAuthForWebhook(cfg *webhook.Configuration) transport.AuthMethod {
+	if cfg.UserName == "" && cfg.Password == "" {
+		return nil
+	}
+
+	return &http.BasicAuth{
+		Username: cfg.UserName,
+		Password: cfg.Password,
+	}
+}
+
+// initAuthForSSH switches the consumer to clone over SSH when enabled for the subcommand.
+func initAuthForSSH(cfg *sshauth.Configuration, consumer *run.Consumer) error {
+	if !cfg.Enabled {
+		return nil
+	}
+
+	authMethod, err := sshauth.New(cfg)
+	if err != nil {
+		return errors.Wrap(err, "failed to setup ssh authentication")
+	}
+
+	consumer.AuthMethod = authMethod
+	consumer.CloneOverSSH = true
+	return nil
+}
+
+func openState(cfg *state.Configuration, scratchDir string) (*state.Store, error) {
+	if cfg.File == "" {
+		cfg.File = path.Join(scratchDir, "state.json")
+	}
+
+	store, err := state.Open(cfg)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to open state file")
+	}
+	return store, nil
+}
+
+func openSyncCache(cfg *synccache.Configuration, scratchDir string) (*synccache.Cache, error) {
+	if cfg.File == "" {
+		cfg.File = path.Join(scratchDir, "sync-cache.json")
+	}
+
+	cache, err := synccache.Open(cfg)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to open sync cache")
+	}
+	return cache, nil
+}
+
+// openMirrors returns nil when the mirror cache is disabled, so repositories are cloned on every run.
+func openMirrors(cfg *mirror.Configuration, scratchDir string) (*mirror.Cache, error) {
+	if !cfg.Enabled {
+		return nil, nil
+	}
+
+	if cfg.Dir == "" {
+		cfg.Dir = path.Join(scratchDir, "mirrors")
+	}
+
+	mirrors, err := mirror.Open(cfg)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to open mirror cache")
+	}
+	return mirrors, nil
+}
+
 func main() {
 	clientConfig, clientFlags := effx.DefaultConfigWithFlags()
 	githubConfig, githubFlags := github.DefaultConfigWithFlags()
 	gitlabConfig, gitlabFlags := gitlab.DefaultConfigWithFlags()
+	bitbucketConfig, bitbucketFlags := bitbucket.DefaultConfigWithFlags()
+	bitbucketServerConfig, bitbucketServerFlags := bitbucketserver.DefaultConfigWithFlags()
+	azureDevOpsConfig, azureDevOpsFlags := azuredevops.DefaultConfigWithFlags()
+	giteaConfig, giteaFlags := gitea.DefaultConfigWithFlags()
+	localConfig, localFlags := local.DefaultConfigWithFlags()
+	listConfig, listFlags := list.DefaultConfigWithFlags()
+	webhookConfig, webhookFlags := webhook.DefaultConfigWithFlags()
 	controllerConfig, controllerFlags := controller.DefaultConfigWithFlags()
+	sshConfig, sshFlags := sshauth.DefaultConfigWithFlags()
+	filterConfig, filterFlags := filter.DefaultConfigWithFlags()
+	mappingConfig, mappingFlags := mapping.DefaultConfigWithFlags()
+	stateConfig, stateFlags := state.DefaultConfigWithFlags()
+	reportConfig, reportFlags := report.DefaultConfigWithFlags()
+	metricsConfig, metricsFlags := metrics.DefaultConfigWithFlags()
+	tracingConfig, tracingFlags := tracing.DefaultConfigWithFlags()
+	syncCacheConfig, syncCacheFlags := synccache.DefaultConfigWithFlags()
+	mirrorConfig, mirrorFlags := mirror.DefaultConfigWithFlags()
+
+	flags := make([]cli.Flag, 0, len(controllerFlags)+len(clientFlags)+len(syncCacheFlags)+len(reportFlags)+len(metricsFlags)+len(tracingFlags))
+	flags = append(append(append(append(append(append(flags, controllerFlags...), clientFlags...), syncCacheFlags...), reportFlags...), metricsFlags...), tracingFlags...)
+
+	// flushes pending spans once a subcommand completes
+	shutdownTracing := func(context.Context) error { return nil }
+
+	// shared by subcommands, so it can be closed once they complete
+	var effxClient *effx.Client
+
+	// setup the effx client, serve metrics and setup tracing while a subcommand runs
+	before := func(ctx *cli.Context) (err error) {
+		// stdout is shared with logs, so requests are written to a file unless asked otherwise
+		if clientConfig.DryRun && clientConfig.DryRunOutput == "" {
+			clientConfig.DryRunOutput = path.Join(controllerConfig.ScratchDir, "dry-run.ndjson")
+		}
+
+		effxClient, err = effx.New(clientConfig)
+		if err != nil {
+			return errors.Wrapf(err, "failed to setup effx client")
+		}
+
+		listener, err := metrics.Listen(metricsConfig)
+		if err != nil {
+			return err
+		}
+
+		// metrics are not worth interrupting a run for once the address is bound
+		if listener != nil {
+			go func() {
+				if err := metrics.Serve(listener); err != nil {
+					log.Println(errors.Wrap(err, "failed to serve metrics").Error())
+				}
+			}()
+		}
+
+		shutdown, err := tracing.Setup(ctx.Context, tracingConfig)
+		if err != nil {
+			return errors.Wrap(err, "failed to setup tracing")
+		}
+		shutdownTracing = shutdown
+		return nil
+	}
 
-	flags := append(controllerFlags, clientFlags...)
+	// close dry run output, push metrics and flush spans once a subcommand completes
+	after := func(ctx *cli.Context) error {
+		shutdownCtx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+		defer cancel()
+
+		// after also runs when before fails to setup the client
+		var err error
+		if effxClient != nil {
+			err = effxClient.Close()
+		}
+
+		return multierr.Combine(
+			err,
+			metrics.Push(metricsConfig),
+			shutdownTracing(shutdownCtx),
+		)
+	}
+
+	// sized exactly so subcommands appending to it never share a backing array
+	cloneFlags := make([]cli.Flag, 0, len(flags)+len(sshFlags)+len(mirrorFlags))
+	cloneFlags = append(append(append(cloneFlags, flags...), sshFlags...), mirrorFlags...)
+
+	serveFlags := make([]cli.Flag, 0, len(cloneFlags)+len(filterFlags)+len(mappingFlags)+len(webhookFlags))
+	serveFlags = append(append(append(append(serveFlags, cloneFlags...), filterFlags...), mappingFlags...), webhookFlags...)
+
+	discoveryFlags := make([]cli.Flag, 0, len(cloneFlags)+len(filterFlags)+len(mappingFlags)+len(stateFlags))
+	discoveryFlags = append(append(append(append(discoveryFlags, cloneFlags...), filterFlags...), mappingFlags...), stateFlags...)
 
 	app := &cli.App{
 		Name:  "vcs-connect",
 		Usage: "Index effx.yaml files in connected version control systems.",
 		Commands: []*cli.Command{
 			{
-				Name:  "github",
-				Usage: "Index repositories connected via GitHub",
-				Flags: append(flags, githubFlags...),
+				Name:   "github",
+				Usage:  "Index repositories connected via GitHub",
+				Flags:  append(discoveryFlags, githubFlags...),
+				Before: before,
+				After:  after,
 				Action: func(ctx *cli.Context) error {
-					effxClient, err := effx.New(clientConfig)
+					reporter, err := report.New(reportConfig)
 					if err != nil {
-						return errors.Wrapf(err, "failed to setup effx client")
+						return errors.Wrap(err, "failed to setup run report")
 					}
 
-					integration, err := github.NewIntegration(ctx.Context, githubConfig)
+					if err := filterConfig.Supported("GitHub", github.ReportedProperties); err != nil {
+						return err
+					}
+
+					repoFilter, err := filter.New(filterConfig)
+					if err != nil {
+						return errors.Wrap(err, "failed to setup repository filter")
+					}
+
+					repoMapping, err := mapping.New(mappingConfig)
+					if err != nil {
+						return errors.Wrap(err, "failed to setup metadata mapping")
+					}
+
+					store, err := openState(stateConfig, controllerConfig.ScratchDir)
+					if err != nil {
+						return err
+					}
+
+					integration, err := github.NewIntegration(ctx.Context, githubConfig, metrics.Filter("github", reporter.Filter(integrations.Filters{repoFilter, store})))
 					if err != nil {
 						return errors.Wrap(err, "failed to setup GitHub integration")
 					}
 
+					syncCache, err := openSyncCache(syncCacheConfig, controllerConfig.ScratchDir)
+					if err != nil {
+						return err
+					}
+
+					mirrors, err := openMirrors(mirrorConfig, controllerConfig.ScratchDir)
+					if err != nil {
+						return err
+					}
+
 					consumer := &run.Consumer{
 						EffxClient: effxClient,
 						ScratchDir: controllerConfig.ScratchDir,
-						AuthMethod: initAuthForGitHub(githubConfig),
+						Report:     reporter,
+						SyncCache:  syncCache,
+						Mirrors:    mirrors,
+						AuthMethod: initAuthForGitHub(githubConfig, integration),
+						Mapping:    repoMapping,
+						State:      store,
+					}
+
+					if err := initAuthForSSH(sshConfig, consumer); err != nil {
+						return err
 					}
 
 					control, err := controller.New(controllerConfig, integration, consumer)
@@ -82,24 +340,505 @@ func main() {
 				},
 			},
 			{
-				Name:  "gitlab",
-				Usage: "Index repositories connected via GitLab",
-				Flags: append(flags, gitlabFlags...),
+				Name:   "gitlab",
+				Usage:  "Index repositories connected via GitLab",
+				Flags:  append(discoveryFlags, gitlabFlags...),
+				Before: before,
+				After:  after,
 				Action: func(ctx *cli.Context) error {
-					effxClient, err := effx.New(clientConfig)
+					reporter, err := report.New(reportConfig)
+					if err != nil {
+						return errors.Wrap(err, "failed to setup run report")
+					}
+
+					if err := filterConfig.Supported("GitLab", gitlab.ReportedProperties); err != nil {
+						return err
+					}
+
+					repoFilter, err := filter.New(filterConfig)
+					if err != nil {
+						return errors.Wrap(err, "failed to setup repository filter")
+					}
+
+					repoMapping, err := mapping.New(mappingConfig)
 					if err != nil {
-						return errors.Wrapf(err, "failed to setup effx client")
+						return errors.Wrap(err, "failed to setup metadata mapping")
 					}
 
-					integration, err := gitlab.NewIntegration(ctx.Context, gitlabConfig)
+					store, err := openState(stateConfig, controllerConfig.ScratchDir)
+					if err != nil {
+						return err
+					}
+
+					integration, err := gitlab.NewIntegration(ctx.Context, gitlabConfig, metrics.Filter("gitlab", reporter.Filter(integrations.Filters{repoFilter, store})))
 					if err != nil {
 						return errors.Wrap(err, "failed to setup GitLab integration")
 					}
 
+					syncCache, err := openSyncCache(syncCacheConfig, controllerConfig.ScratchDir)
+					if err != nil {
+						return err
+					}
+
+					mirrors, err := openMirrors(mirrorConfig, controllerConfig.ScratchDir)
+					if err != nil {
+						return err
+					}
+
+					consumer := &run.Consumer{
+						EffxClient: effxClient,
+						ScratchDir: controllerConfig.ScratchDir,
+						Report:     reporter,
+						SyncCache:  syncCache,
+						Mirrors:    mirrors,
+						AuthMethod: initAuthForGitLab(gitlabConfig),
+						Mapping:    repoMapping,
+						State:      store,
+					}
+
+					if err := initAuthForSSH(sshConfig, consumer); err != nil {
+						return err
+					}
+
+					control, err := controller.New(controllerConfig, integration, consumer)
+					if err != nil {
+						return errors.Wrapf(err, "failed to setup controller")
+					}
+
+					return control.Run(ctx.Context)
+				},
+			},
+			{
+				Name:   "bitbucket",
+				Usage:  "Index repositories connected via Bitbucket Cloud",
+				Flags:  append(discoveryFlags, bitbucketFlags...),
+				Before: before,
+				After:  after,
+				Action: func(ctx *cli.Context) error {
+					reporter, err := report.New(reportConfig)
+					if err != nil {
+						return errors.Wrap(err, "failed to setup run report")
+					}
+
+					if err := filterConfig.Supported("Bitbucket", bitbucket.ReportedProperties); err != nil {
+						return err
+					}
+
+					repoFilter, err := filter.New(filterConfig)
+					if err != nil {
+						return errors.Wrap(err, "failed to setup repository filter")
+					}
+
+					repoMapping, err := mapping.New(mappingConfig)
+					if err != nil {
+						return errors.Wrap(err, "failed to setup metadata mapping")
+					}
+
+					store, err := openState(stateConfig, controllerConfig.ScratchDir)
+					if err != nil {
+						return err
+					}
+
+					integration, err := bitbucket.NewIntegration(ctx.Context, bitbucketConfig, metrics.Filter("bitbucket", reporter.Filter(integrations.Filters{repoFilter, store})))
+					if err != nil {
+						return errors.Wrap(err, "failed to setup Bitbucket integration")
+					}
+
+					syncCache, err := openSyncCache(syncCacheConfig, controllerConfig.ScratchDir)
+					if err != nil {
+						return err
+					}
+
+					mirrors, err := openMirrors(mirrorConfig, controllerConfig.ScratchDir)
+					if err != nil {
+						return err
+					}
+
+					consumer := &run.Consumer{
+						EffxClient: effxClient,
+						ScratchDir: controllerConfig.ScratchDir,
+						Report:     reporter,
+						SyncCache:  syncCache,
+						Mirrors:    mirrors,
+						AuthMethod: initAuthForBitbucket(bitbucketConfig),
+						Mapping:    repoMapping,
+						State:      store,
+					}
+
+					if err := initAuthForSSH(sshConfig, consumer); err != nil {
+						return err
+					}
+
+					control, err := controller.New(controllerConfig, integration, consumer)
+					if err != nil {
+						return errors.Wrapf(err, "failed to setup controller")
+					}
+
+					return control.Run(ctx.Context)
+				},
+			},
+			{
+				Name:   "bitbucket-server",
+				Usage:  "Index repositories connected via Bitbucket Server or Data Center",
+				Flags:  append(discoveryFlags, bitbucketServerFlags...),
+				Before: before,
+				After:  after,
+				Action: func(ctx *cli.Context) error {
+					reporter, err := report.New(reportConfig)
+					if err != nil {
+						return errors.Wrap(err, "failed to setup run report")
+					}
+
+					if err := filterConfig.Supported("Bitbucket Server", bitbucketserver.ReportedProperties); err != nil {
+						return err
+					}
+
+					repoFilter, err := filter.New(filterConfig)
+					if err != nil {
+						return errors.Wrap(err, "failed to setup repository filter")
+					}
+
+					repoMapping, err := mapping.New(mappingConfig)
+					if err != nil {
+						return errors.Wrap(err, "failed to setup metadata mapping")
+					}
+
+					store, err := openState(stateConfig, controllerConfig.ScratchDir)
+					if err != nil {
+						return err
+					}
+
+					integration, err := bitbucketserver.NewIntegration(ctx.Context, bitbucketServerConfig, metrics.Filter("bitbucket-server", reporter.Filter(integrations.Filters{repoFilter, store})))
+					if err != nil {
+						return errors.Wrap(err, "failed to setup Bitbucket Server integration")
+					}
+
+					syncCache, err := openSyncCache(syncCacheConfig, controllerConfig.ScratchDir)
+					if err != nil {
+						return err
+					}
+
+					mirrors, err := openMirrors(mirrorConfig, controllerConfig.ScratchDir)
+					if err != nil {
+						return err
+					}
+
+					consumer := &run.Consumer{
+						EffxClient: effxClient,
+						ScratchDir: controllerConfig.ScratchDir,
+						Report:     reporter,
+						SyncCache:  syncCache,
+						Mirrors:    mirrors,
+						AuthMethod: initAuthForBitbucketServer(bitbucketServerConfig),
+						Mapping:    repoMapping,
+						State:      store,
+					}
+
+					if err := initAuthForSSH(sshConfig, consumer); err != nil {
+						return err
+					}
+
+					control, err := controller.New(controllerConfig, integration, consumer)
+					if err != nil {
+						return errors.Wrapf(err, "failed to setup controller")
+					}
+
+					return control.Run(ctx.Context)
+				},
+			},
+			{
+				Name:   "azuredevops",
+				Usage:  "Index repositories connected via Azure DevOps",
+				Flags:  append(discoveryFlags, azureDevOpsFlags...),
+				Before: before,
+				After:  after,
+				Action: func(ctx *cli.Context) error {
+					reporter, err := report.New(reportConfig)
+					if err != nil {
+						return errors.Wrap(err, "failed to setup run report")
+					}
+
+					if err := filterConfig.Supported("Azure DevOps", azuredevops.ReportedProperties); err != nil {
+						return err
+					}
+
+					repoFilter, err := filter.New(filterConfig)
+					if err != nil {
+						return errors.Wrap(err, "failed to setup repository filter")
+					}
+
+					repoMapping, err := mapping.New(mappingConfig)
+					if err != nil {
+						return errors.Wrap(err, "failed to setup metadata mapping")
+					}
+
+					store, err := openState(stateConfig, controllerConfig.ScratchDir)
+					if err != nil {
+						return err
+					}
+
+					integration, err := azuredevops.NewIntegration(ctx.Context, azureDevOpsConfig, metrics.Filter("azuredevops", reporter.Filter(integrations.Filters{repoFilter, store})))
+					if err != nil {
+						return errors.Wrap(err, "failed to setup Azure DevOps integration")
+					}
+
+					syncCache, err := openSyncCache(syncCacheConfig, controllerConfig.ScratchDir)
+					if err != nil {
+						return err
+					}
+
+					mirrors, err := openMirrors(mirrorConfig, controllerConfig.ScratchDir)
+					if err != nil {
+						return err
+					}
+
+					consumer := &run.Consumer{
+						EffxClient: effxClient,
+						ScratchDir: controllerConfig.ScratchDir,
+						Report:     reporter,
+						SyncCache:  syncCache,
+						Mirrors:    mirrors,
+						AuthMethod: initAuthForAzureDevOps(azureDevOpsConfig),
+						Mapping:    repoMapping,
+						State:      store,
+					}
+
+					if err := initAuthForSSH(sshConfig, consumer); err != nil {
+						return err
+					}
+
+					control, err := controller.New(controllerConfig, integration, consumer)
+					if err != nil {
+						return errors.Wrapf(err, "failed to setup controller")
+					}
+
+					return control.Run(ctx.Context)
+				},
+			},
+			{
+				Name:   "gitea",
+				Usage:  "Index repositories connected via Gitea or Forgejo",
+				Flags:  append(discoveryFlags, giteaFlags...),
+				Before: before,
+				After:  after,
+				Action: func(ctx *cli.Context) error {
+					reporter, err := report.New(reportConfig)
+					if err != nil {
+						return errors.Wrap(err, "failed to setup run report")
+					}
+
+					if err := filterConfig.Supported("Gitea", gitea.ReportedProperties); err != nil {
+						return err
+					}
+
+					repoFilter, err := filter.New(filterConfig)
+					if err != nil {
+						return errors.Wrap(err, "failed to setup repository filter")
+					}
+
+					repoMapping, err := mapping.New(mappingConfig)
+					if err != nil {
+						return errors.Wrap(err, "failed to setup metadata mapping")
+					}
+
+					store, err := openState(stateConfig, controllerConfig.ScratchDir)
+					if err != nil {
+						return err
+					}
+
+					integration, err := gitea.NewIntegration(ctx.Context, giteaConfig, metrics.Filter("gitea", reporter.Filter(integrations.Filters{repoFilter, store})))
+					if err != nil {
+						return errors.Wrap(err, "failed to setup Gitea integration")
+					}
+
+					syncCache, err := openSyncCache(syncCacheConfig, controllerConfig.ScratchDir)
+					if err != nil {
+						return err
+					}
+
+					mirrors, err := openMirrors(mirrorConfig, controllerConfig.ScratchDir)
+					if err != nil {
+						return err
+					}
+
+					consumer := &run.Consumer{
+						EffxClient: effxClient,
+						ScratchDir: controllerConfig.ScratchDir,
+						Report:     reporter,
+						SyncCache:  syncCache,
+						Mirrors:    mirrors,
+						AuthMethod: initAuthForGitea(giteaConfig),
+						Mapping:    repoMapping,
+						State:      store,
+					}
+
+					if err := initAuthForSSH(sshConfig, consumer); err != nil {
+						return err
+					}
+
+					control, err := controller.New(controllerConfig, integration, consumer)
+					if err != nil {
+						return errors.Wrapf(err, "failed to setup controller")
+					}
+
+					return control.Run(ctx.Context)
+				},
+			},
+			{
+				Name:   "local",
+				Usage:  "Index directories already checked out on the local filesystem",
+				Flags:  append(flags, localFlags...),
+				Before: before,
+				After:  after,
+				Action: func(ctx *cli.Context) error {
+					reporter, err := report.New(reportConfig)
+					if err != nil {
+						return errors.Wrap(err, "failed to setup run report")
+					}
+
+					integration, err := local.NewIntegration(ctx.Context, localConfig)
+					if err != nil {
+						return errors.Wrap(err, "failed to setup local integration")
+					}
+
+					syncCache, err := openSyncCache(syncCacheConfig, controllerConfig.ScratchDir)
+					if err != nil {
+						return err
+					}
+
+					consumer := &run.Consumer{
+						EffxClient: effxClient,
+						ScratchDir: controllerConfig.ScratchDir,
+						Report:     reporter,
+						SyncCache:  syncCache,
+					}
+
+					control, err := controller.New(controllerConfig, integration, consumer)
+					if err != nil {
+						return errors.Wrapf(err, "failed to setup controller")
+					}
+
+					return control.Run(ctx.Context)
+				},
+			},
+			{
+				Name:   "list",
+				Usage:  "Index repositories read from a file or stdin",
+				Flags:  append(cloneFlags, listFlags...),
+				Before: before,
+				After:  after,
+				Action: func(ctx *cli.Context) error {
+					// stdin is consumed by the first pass, leaving nothing for later ones
+					if listConfig.File == "-" && controllerConfig.IsDaemon() {
+						return fmt.Errorf("schedule and interval require a list file instead of stdin")
+					}
+
+					reporter, err := report.New(reportConfig)
+					if err != nil {
+						return errors.Wrap(err, "failed to setup run report")
+					}
+
+					integration, err := list.NewIntegration(ctx.Context, listConfig)
+					if err != nil {
+						return errors.Wrap(err, "failed to setup list integration")
+					}
+
+					syncCache, err := openSyncCache(syncCacheConfig, controllerConfig.ScratchDir)
+					if err != nil {
+						return err
+					}
+
+					mirrors, err := openMirrors(mirrorConfig, controllerConfig.ScratchDir)
+					if err != nil {
+						return err
+					}
+
+					consumer := &run.Consumer{
+						EffxClient: effxClient,
+						ScratchDir: controllerConfig.ScratchDir,
+						Report:     reporter,
+						SyncCache:  syncCache,
+						Mirrors:    mirrors,
+						AuthMethod: initAuthForList(listConfig),
+					}
+
+					if err := initAuthForSSH(sshConfig, consumer); err != nil {
+						return err
+					}
+
+					control, err := controller.New(controllerConfig, integration, consumer)
+					if err != nil {
+						return errors.Wrapf(err, "failed to setup controller")
+					}
+
+					return control.Run(ctx.Context)
+				},
+			},
+			{
+				Name:   "serve",
+				Usage:  "Index repositories as GitHub and GitLab push webhooks are received",
+				Flags:  serveFlags,
+				Before: before,
+				After:  after,
+				Action: func(ctx *cli.Context) error {
+					// the server runs until shutdown, so there are no passes to repeat
+					if controllerConfig.IsDaemon() {
+						return fmt.Errorf("schedule and interval are not supported by the serve command")
+					}
+
+					reporter, err := report.New(reportConfig)
+					if err != nil {
+						return errors.Wrap(err, "failed to setup run report")
+					}
+
+					if webhookConfig.GitHubSecret != "" {
+						if err := filterConfig.Supported("GitHub webhooks", webhook.GitHubReportedProperties); err != nil {
+							return err
+						}
+					}
+					if webhookConfig.GitLabToken != "" {
+						if err := filterConfig.Supported("GitLab webhooks", webhook.GitLabReportedProperties); err != nil {
+							return err
+						}
+					}
+
+					repoFilter, err := filter.New(filterConfig)
+					if err != nil {
+						return errors.Wrap(err, "failed to setup repository filter")
+					}
+
+					repoMapping, err := mapping.New(mappingConfig)
+					if err != nil {
+						return errors.Wrap(err, "failed to setup metadata mapping")
+					}
+
+					integration, err := webhook.NewIntegration(ctx.Context, webhookConfig, metrics.Filter("webhook", reporter.Filter(repoFilter)))
+					if err != nil {
+						return errors.Wrap(err, "failed to setup webhook integration")
+					}
+
+					syncCache, err := openSyncCache(syncCacheConfig, controllerConfig.ScratchDir)
+					if err != nil {
+						return err
+					}
+
+					mirrors, err := openMirrors(mirrorConfig, controllerConfig.ScratchDir)
+					if err != nil {
+						return err
+					}
+
 					consumer := &run.Consumer{
 						EffxClient: effxClient,
 						ScratchDir: controllerConfig.ScratchDir,
-						AuthMethod: initAuthForGitHub(githubConfig),
+						Report:     reporter,
+						SyncCache:  syncCache,
+						Mirrors:    mirrors,
+						AuthMethod: initAuthForWebhook(webhookConfig),
+						Mapping:    repoMapping,
+					}
+
+					if err := initAuthForSSH(sshConfig, consumer); err != nil {
+						return err
 					}
 
 					control, err := controller.New(controllerConfig, integration, consumer)
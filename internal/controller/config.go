@@ -4,6 +4,9 @@ import (
 	"fmt"
 	"os"
 	"path"
+	"time"
+
+	"github.com/robfig/cron/v3"
 
 	"github.com/urfave/cli/v2"
 )
@@ -12,6 +15,16 @@ import (
 type Configuration struct {
 	ScratchDir string
 	Workers    int
+	Schedule   string
+	Interval   time.Duration
+	Jitter     time.Duration
+	// DrainTimeout bounds how long in-flight repositories may finish after shutdown is requested.
+	DrainTimeout time.Duration
+}
+
+// IsDaemon returns true when passes should repeat until the program is shutdown.
+func (c *Configuration) IsDaemon() bool {
+	return c.Schedule != "" || c.Interval > 0
 }
 
 // Validate ensures the configuration provided contains the required information.
@@ -20,6 +33,14 @@ func (c *Configuration) Validate() error {
 		return fmt.Errorf("a scratch dir must be provided")
 	} else if c.Workers <= 0 {
 		return fmt.Errorf("at least one worker must be configured")
+	} else if c.Schedule != "" && c.Interval > 0 {
+		return fmt.Errorf("only one of schedule or interval may be provided")
+	} else if c.Interval < 0 || c.Jitter < 0 || c.DrainTimeout < 0 {
+		return fmt.Errorf("interval, jitter and drain timeout must not be negative")
+	} else if c.Schedule != "" {
+		if _, err := cron.ParseStandard(c.Schedule); err != nil {
+			return fmt.Errorf("invalid schedule: %v", err)
+		}
 	}
 	return nil
 }
@@ -27,8 +48,9 @@ func (c *Configuration) Validate() error {
 // DefaultConfigWithFlags returns configuration and flags specific to the control loop.
 func DefaultConfigWithFlags() (*Configuration, []cli.Flag) {
 	cfg := &Configuration{
-		ScratchDir: path.Join(os.TempDir(), "effx-vcs-connect"),
-		Workers:    1,
+		ScratchDir:   path.Join(os.TempDir(), "effx-vcs-connect"),
+		Workers:      1,
+		DrainTimeout: time.Minute,
 	}
 
 	flags := []cli.Flag{
@@ -46,6 +68,34 @@ func DefaultConfigWithFlags() (*Configuration, []cli.Flag) {
 			Value:       cfg.Workers,
 			EnvVars:     []string{"WORKERS"},
 		},
+		&cli.StringFlag{
+			Name:        "schedule",
+			Usage:       "cron expression on which passes repeat, runs a single pass when omitted",
+			Destination: &(cfg.Schedule),
+			Value:       cfg.Schedule,
+			EnvVars:     []string{"SCHEDULE"},
+		},
+		&cli.DurationFlag{
+			Name:        "interval",
+			Usage:       "delay between the end of one pass and the start of the next, runs a single pass when omitted",
+			Destination: &(cfg.Interval),
+			Value:       cfg.Interval,
+			EnvVars:     []string{"INTERVAL"},
+		},
+		&cli.DurationFlag{
+			Name:        "jitter",
+			Usage:       "upper bound of a random delay added before each scheduled pass",
+			Destination: &(cfg.Jitter),
+			Value:       cfg.Jitter,
+			EnvVars:     []string{"JITTER"},
+		},
+		&cli.DurationFlag{
+			Name:        "drain-timeout",
+			Usage:       "how long repositories being indexed may finish after a shutdown signal",
+			Destination: &(cfg.DrainTimeout),
+			Value:       cfg.DrainTimeout,
+			EnvVars:     []string{"DRAIN_TIMEOUT"},
+		},
 	}
 
 	return cfg, flags
@@ -2,16 +2,33 @@ package controller
 
 import (
 	"context"
+	"math/rand"
 	"os"
 	"os/signal"
+	"sync"
 	"syscall"
+	"time"
 
 	"github.com/effxhq/vcs-connect/internal/integrations"
 	"github.com/effxhq/vcs-connect/internal/logger"
+	"github.com/effxhq/vcs-connect/internal/metrics"
 	"github.com/effxhq/vcs-connect/internal/model"
 	"github.com/effxhq/vcs-connect/internal/run"
+	"github.com/effxhq/vcs-connect/internal/tracing"
+
+	"github.com/pkg/errors"
+
+	"github.com/robfig/cron/v3"
+
+	"go.opentelemetry.io/otel/trace"
+
+	"go.uber.org/multierr"
+	"go.uber.org/zap"
 )
 
+// flushInterval bounds how much progress is lost should a long pass be interrupted.
+const flushInterval = time.Minute
+
 // New returns a new controller that manages the pipeline between the integration and the consumers
 func New(
 	cfg *Configuration,
@@ -23,42 +40,248 @@ func New(
 		return nil, err
 	}
 
+	var schedule cron.Schedule
+	if cfg.Schedule != "" {
+		// validated above
+		schedule, _ = cron.ParseStandard(cfg.Schedule)
+	} else if cfg.Interval > 0 {
+		schedule = interval(cfg.Interval)
+	}
+
 	return &Controller{
-		integration: integration,
-		consumer:    consumer,
-		workers:     cfg.Workers,
+		integration:  integration,
+		consumer:     consumer,
+		workers:      cfg.Workers,
+		schedule:     schedule,
+		jitter:       cfg.Jitter,
+		drainTimeout: cfg.DrainTimeout,
+		// seeded for each controller, so replicas do not share the same jitter
+		rand:    rand.New(rand.NewSource(time.Now().UnixNano())),
+		signals: make(chan os.Signal, 1),
 	}, nil
 }
 
+// interval delays the next pass from the end of the previous one. Unlike
+// cron.Every, delays shorter than a second are not rounded.
+type interval time.Duration
+
+// Next returns the time following the provided one by the interval.
+func (i interval) Next(t time.Time) time.Time {
+	return t.Add(time.Duration(i))
+}
+
 // Controller encapsulates the logic of spinning up multiple workers to feed from
 // a common integration
 type Controller struct {
 	integration integrations.Runner
 	consumer    *run.Consumer
 	workers     int
+	// schedule is nil when only a single pass is performed
+	schedule     cron.Schedule
+	jitter       time.Duration
+	drainTimeout time.Duration
+	rand         *rand.Rand
+	// signals requests shutdown, the first one drains and the second one interrupts
+	signals chan os.Signal
 }
 
-// Run performs a single pass over the data.
+// Run performs a single pass over the data, or repeats passes on the configured
+// schedule until the program is shutdown.
 func (c *Controller) Run(parent context.Context) error {
-	ctx, cancel := context.WithCancel(parent)
-	defer cancel()
+	// work is cancelled once in-flight repositories had the chance to drain
+	work, cancelWork := context.WithCancel(parent)
+	defer cancelWork()
+
+	work = logger.AttachToContext(work, logger.MustSetup())
+
+	// produce is cancelled as soon as shutdown is requested
+	produce, cancelProduce := context.WithCancel(work)
+	defer cancelProduce()
+
+	go c.handleSignals(work, cancelProduce, cancelWork)
+
+	if c.schedule == nil {
+		return c.pass(produce, work)
+	}
+
+	return c.daemon(produce, work)
+}
+
+// handleSignals stops producing on the first signal, and stops working once the
+// drain timeout elapses or a second signal is received.
+func (c *Controller) handleSignals(work context.Context, cancelProduce, cancelWork context.CancelFunc) {
+	log := logger.MustGetFromContext(work)
+
+	signal.Notify(c.signals, syscall.SIGTERM, syscall.SIGINT)
+	defer signal.Stop(c.signals)
+
+	select {
+	case <-work.Done():
+		return
+	case <-c.signals:
+	}
+
+	log.Info("shutting down, waiting for in-flight repositories",
+		zap.Duration("drainTimeout", c.drainTimeout))
+	cancelProduce()
+
+	timer := time.NewTimer(c.drainTimeout)
+	defer timer.Stop()
+
+	select {
+	case <-work.Done():
+		return
+	case <-timer.C:
+		log.Warn("drain timeout elapsed, interrupting in-flight repositories")
+	case <-c.signals:
+		log.Warn("received second signal, interrupting in-flight repositories")
+	}
+	cancelWork()
+}
+
+// next returns when the pass following the provided time should start, including jitter.
+func (c *Controller) next(now time.Time) time.Time {
+	next := c.schedule.Next(now)
+	if c.jitter > 0 {
+		next = next.Add(time.Duration(c.rand.Int63n(int64(c.jitter))))
+	}
+	return next
+}
+
+// daemon repeats passes until the context is cancelled. Passes never overlap,
+// scheduled times missed while a pass is running are skipped.
+func (c *Controller) daemon(produce, work context.Context) error {
+	log := logger.MustGetFromContext(work)
+
+	// cron schedules wait for their first occurrence, intervals start immediately
+	start := time.Now()
+	if _, ok := c.schedule.(interval); !ok {
+		start = c.next(start)
+	}
+
+	for iteration := 1; ; iteration++ {
+		log.Info("waiting for next pass",
+			zap.Int("pass", iteration),
+			zap.Time("start", start))
+
+		timer := time.NewTimer(time.Until(start))
+		select {
+		case <-produce.Done():
+			timer.Stop()
+			return nil
+		case <-timer.C:
+		}
+
+		scheduled := c.schedule.Next(start)
+		began := time.Now()
+		err := c.pass(produce, work)
+		finished := time.Now()
+
+		fields := []zap.Field{
+			zap.Int("pass", iteration),
+			zap.Duration("duration", finished.Sub(began)),
+		}
+
+		if err != nil {
+			log.Error("pass failed", append(fields, zap.Error(err))...)
+		} else {
+			log.Info("pass complete", fields...)
+		}
+
+		if produce.Err() != nil {
+			return err
+		}
+
+		if _, ok := c.schedule.(interval); !ok && finished.After(scheduled) {
+			log.Warn("pass overran its schedule, skipping missed passes",
+				zap.Int("pass", iteration),
+				zap.Time("missed", scheduled))
+		}
+
+		start = c.next(finished)
+	}
+}
 
-	ctx = logger.AttachToContext(ctx, logger.MustSetup())
+// flush periodically writes the progress of the consumer to disk until done is closed.
+func (c *Controller) flush(ctx context.Context, done chan struct{}) {
+	log := logger.MustGetFromContext(ctx)
+
+	ticker := time.NewTicker(flushInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-done:
+			return
+		case <-ticker.C:
+			if err := c.consumer.Flush(); err != nil {
+				log.Error("failed to flush progress", zap.Error(err))
+			}
+		}
+	}
+}
+
+// pass runs the integration once, feeding the discovered repositories to the
+// workers. The integration stops when produce is cancelled, after which the
+// workers finish the repositories they already received unless work is cancelled.
+func (c *Controller) pass(produce, work context.Context) (err error) {
+	// repositories are traced separately, linking back to this span
+	produce, span := tracing.Start(produce, "Run")
+	defer func() { tracing.End(span, err) }()
+	work = trace.ContextWithSpan(work, span)
+
+	c.consumer.Begin()
+	rep := c.consumer.Report
+	if rep != nil {
+		rep.Begin()
+	}
 
 	data := make(chan *model.Repository)
+	metrics.Workers.Set(float64(c.workers))
 
-	signals := make(chan os.Signal)
-	signal.Notify(signals, syscall.SIGTERM, syscall.SIGINT)
-	go func() {
-		defer signal.Stop(signals)
-		<-signals
-		cancel()
-	}()
+	var (
+		wg   sync.WaitGroup
+		mu   sync.Mutex
+		errs error
+	)
 
 	for i := 0; i < c.workers; i++ {
-		go c.consumer.Run(ctx, data)
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+
+			if err := c.consumer.Run(work, data); err != nil {
+				mu.Lock()
+				errs = multierr.Append(errs, err)
+				mu.Unlock()
+			}
+		}()
 	}
 
-	// Run the integration until completion
-	return c.integration.Run(ctx, data)
+	flushed := make(chan struct{})
+	go c.flush(work, flushed)
+
+	// Run the integration until completion, then let the workers drain
+	integrationCtx, integrationSpan := tracing.Start(produce, "Integration.Run")
+	err = c.integration.Run(integrationCtx, data)
+	tracing.End(integrationSpan, err)
+
+	close(data)
+	wg.Wait()
+	close(flushed)
+
+	if err != nil {
+		err = errors.Wrap(err, "integration failed")
+	}
+	err = multierr.Append(err, c.consumer.Flush())
+
+	// failures of individual repositories are judged against the report's threshold,
+	// unless they were interrupted before they had the chance to finish
+	if rep != nil {
+		err = multierr.Append(err, rep.End(logger.MustGetFromContext(work)))
+		if work.Err() == nil {
+			return err
+		}
+	}
+	return multierr.Append(err, errs)
 }
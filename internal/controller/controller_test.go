@@ -0,0 +1,270 @@
+package controller_test
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"math/rand"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/effxhq/vcs-connect/internal/controller"
+	"github.com/effxhq/vcs-connect/internal/effx"
+	"github.com/effxhq/vcs-connect/internal/model"
+	"github.com/effxhq/vcs-connect/internal/report"
+	"github.com/effxhq/vcs-connect/internal/run"
+
+	"github.com/stretchr/testify/require"
+)
+
+// passes records when each pass of the integration began and ended, cancelling
+// the run once the limit is reached.
+type passes struct {
+	duration time.Duration
+	limit    int
+	cancel   context.CancelFunc
+
+	mu         sync.Mutex
+	running    int
+	overlapped bool
+	begins     []time.Time
+	ends       []time.Time
+}
+
+func (p *passes) Run(ctx context.Context, data chan *model.Repository) error {
+	p.mu.Lock()
+	p.running++
+	p.overlapped = p.overlapped || p.running > 1
+	p.begins = append(p.begins, time.Now())
+	n := len(p.begins)
+	p.mu.Unlock()
+
+	time.Sleep(p.duration)
+
+	p.mu.Lock()
+	p.running--
+	p.ends = append(p.ends, time.Now())
+	p.mu.Unlock()
+
+	if n == p.limit {
+		p.cancel()
+	}
+	return nil
+}
+
+// aligned occurs on every multiple of the period, recording the times it was asked about.
+type aligned struct {
+	period time.Duration
+
+	mu    sync.Mutex
+	calls []time.Time
+}
+
+func (s *aligned) Next(t time.Time) time.Time {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.calls = append(s.calls, t)
+	return t.Truncate(s.period).Add(s.period)
+}
+
+func newController(t *testing.T, cfg *controller.Configuration, integration *passes) *controller.Controller {
+	cfg.ScratchDir = t.TempDir()
+	cfg.Workers = 1
+
+	c, err := controller.New(cfg, integration, &run.Consumer{})
+	require.NoError(t, err)
+	return c
+}
+
+func TestController_SinglePass(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	integration := &passes{cancel: cancel}
+	c := newController(t, &controller.Configuration{}, integration)
+
+	require.NoError(t, c.Run(ctx))
+	require.Len(t, integration.begins, 1)
+	require.NoError(t, ctx.Err())
+}
+
+func TestController_Interval(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	integration := &passes{limit: 3, cancel: cancel}
+	c := newController(t, &controller.Configuration{Interval: 10 * time.Millisecond}, integration)
+
+	require.NoError(t, c.Run(ctx))
+	require.Len(t, integration.begins, 3)
+
+	// the interval is measured from the end of the previous pass
+	for i := 1; i < len(integration.begins); i++ {
+		require.GreaterOrEqual(t, integration.begins[i].Sub(integration.ends[i-1]), 10*time.Millisecond)
+	}
+}
+
+func TestController_Schedule(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	// every pass overruns two occurrences of the schedule
+	integration := &passes{duration: 25 * time.Millisecond, limit: 3, cancel: cancel}
+	c := newController(t, &controller.Configuration{}, integration)
+
+	schedule := &aligned{period: 10 * time.Millisecond}
+	c.SetSchedule(schedule)
+
+	require.NoError(t, c.Run(ctx))
+	require.Len(t, integration.begins, 3)
+	require.False(t, integration.overlapped)
+
+	// occurrences missed during a pass are skipped, the next pass is scheduled
+	// from when the previous one finished
+	for i := 1; i < len(integration.begins); i++ {
+		scheduled := false
+		for _, call := range schedule.calls {
+			if !call.Before(integration.ends[i-1]) && !call.After(integration.begins[i]) {
+				scheduled = true
+			}
+		}
+		require.True(t, scheduled, "pass %d was not scheduled after the previous one finished", i+1)
+	}
+}
+
+func TestController_Jitter(t *testing.T) {
+	cfg := &controller.Configuration{Interval: time.Hour, Jitter: time.Minute}
+	c := newController(t, cfg, &passes{})
+	c.SetRand(rand.New(rand.NewSource(1)))
+
+	now := time.Now()
+	seen := map[time.Time]bool{}
+	for i := 0; i < 100; i++ {
+		next := c.Next(now)
+		require.False(t, next.Before(now.Add(time.Hour)))
+		require.True(t, next.Before(now.Add(time.Hour+time.Minute)))
+		seen[next] = true
+	}
+	require.Greater(t, len(seen), 1)
+
+	// replicas started with the same configuration do not share their jitter
+	a := newController(t, &controller.Configuration{Interval: time.Hour, Jitter: time.Hour}, &passes{})
+	b := newController(t, &controller.Configuration{Interval: time.Hour, Jitter: time.Hour}, &passes{})
+	require.NotEqual(t, a.Next(now), b.Next(now))
+}
+
+// repositories hands the provided repositories to the workers once.
+type repositories []*model.Repository
+
+func (r repositories) Run(ctx context.Context, data chan *model.Repository) error {
+	for _, repository := range r {
+		select {
+		case <-ctx.Done():
+			return nil
+		case data <- repository:
+		}
+	}
+	return nil
+}
+
+func pktLine(line string) string {
+	return fmt.Sprintf("%04x%s", len(line)+4, line)
+}
+
+// hangingServer returns a git server that advertises a single branch but never
+// sends its contents, and a channel closed once a clone is in flight.
+func hangingServer(t *testing.T) (*httptest.Server, chan struct{}) {
+	const commit = "0123456789012345678901234567890123456789"
+
+	started := make(chan struct{})
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/effxhq/vcs-connect.git/info/refs", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/x-git-upload-pack-advertisement")
+		fmt.Fprint(w, pktLine("# service=git-upload-pack\n")+"0000"+
+			pktLine(commit+" HEAD\x00symref=HEAD:refs/heads/master\n")+
+			pktLine(commit+" refs/heads/master\n")+"0000")
+	})
+	// advertising references ignores cancellation, fetching them does not
+	mux.HandleFunc("/effxhq/vcs-connect.git/git-upload-pack", func(w http.ResponseWriter, r *http.Request) {
+		_, _ = io.Copy(ioutil.Discard, r.Body)
+		close(started)
+		<-r.Context().Done()
+	})
+
+	server := httptest.NewServer(mux)
+	t.Cleanup(server.Close)
+
+	return server, started
+}
+
+func newDrainingController(t *testing.T, drainTimeout time.Duration, cloneURL string) *controller.Controller {
+	effxClient, err := effx.New(&effx.Configuration{
+		DryRun:       true,
+		DryRunOutput: t.TempDir(),
+	})
+	require.NoError(t, err)
+
+	// failures are judged by the report alone while repositories are not interrupted
+	rep, err := report.New(&report.Configuration{FailureThreshold: 1})
+	require.NoError(t, err)
+
+	scratchDir := t.TempDir()
+	c, err := controller.New(&controller.Configuration{
+		ScratchDir:   scratchDir,
+		Workers:      1,
+		DrainTimeout: drainTimeout,
+	}, repositories{{CloneURL: cloneURL}}, &run.Consumer{
+		EffxClient: effxClient,
+		ScratchDir: scratchDir,
+		Report:     rep,
+	})
+	require.NoError(t, err)
+	return c
+}
+
+func TestController_DrainTimeout(t *testing.T) {
+	server, started := hangingServer(t)
+	c := newDrainingController(t, 50*time.Millisecond, server.URL+"/effxhq/vcs-connect.git")
+
+	result := make(chan error, 1)
+	go func() { result <- c.Run(context.Background()) }()
+
+	<-started
+	signalled := time.Now()
+	c.Signal()
+
+	select {
+	case err := <-result:
+		require.GreaterOrEqual(t, time.Since(signalled), 50*time.Millisecond)
+		require.Error(t, err)
+		require.Contains(t, err.Error(), "failed to consume "+server.URL)
+	case <-time.After(5 * time.Second):
+		t.Fatal("in-flight repositories were not interrupted after the drain timeout")
+	}
+}
+
+func TestController_SecondSignal(t *testing.T) {
+	server, started := hangingServer(t)
+	c := newDrainingController(t, time.Hour, server.URL+"/effxhq/vcs-connect.git")
+
+	result := make(chan error, 1)
+	go func() { result <- c.Run(context.Background()) }()
+
+	<-started
+	c.Signal()
+	c.Signal()
+
+	select {
+	case err := <-result:
+		require.Error(t, err)
+		require.Contains(t, err.Error(), "failed to consume "+server.URL)
+	case <-time.After(5 * time.Second):
+		t.Fatal("in-flight repositories were not interrupted by a second signal")
+	}
+}
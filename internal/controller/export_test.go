@@ -0,0 +1,29 @@
+package controller
+
+import (
+	"math/rand"
+	"syscall"
+	"time"
+
+	"github.com/robfig/cron/v3"
+)
+
+// SetSchedule replaces the schedule passes repeat on.
+func (c *Controller) SetSchedule(schedule cron.Schedule) {
+	c.schedule = schedule
+}
+
+// SetRand replaces the source of jitter.
+func (c *Controller) SetRand(r *rand.Rand) {
+	c.rand = r
+}
+
+// Next returns when the pass following the provided time should start, including jitter.
+func (c *Controller) Next(now time.Time) time.Time {
+	return c.next(now)
+}
+
+// Signal requests shutdown as if the program received SIGTERM.
+func (c *Controller) Signal() {
+	c.signals <- syscall.SIGTERM
+}
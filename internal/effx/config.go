@@ -11,10 +11,20 @@ type Configuration struct {
 	APIHost string
 	APIKey  string
 	Disable cli.StringSlice
+	// DryRun writes sync requests to DryRunOutput instead of sending them to the api.
+	DryRun       bool
+	DryRunOutput string
 }
 
 // Validate ensures the configuration provided contains the required information.
 func (c *Configuration) Validate() error {
+	if c.DryRun {
+		if c.DryRunOutput == "" {
+			return fmt.Errorf("a dry run output must be provided")
+		}
+		return nil
+	}
+
 	if c.APIHost == "" {
 		return fmt.Errorf("an api host must be provided")
 	} else if c.APIKey == "" {
@@ -51,6 +61,20 @@ func DefaultConfigWithFlags() (*Configuration, []cli.Flag) {
 			Value:       &cli.StringSlice{},
 			EnvVars:     []string{"DISABLE"},
 		},
+		&cli.BoolFlag{
+			Name:        "dry-run",
+			Usage:       "write the requests that would be sent to effx instead of sending them",
+			Destination: &(cfg.DryRun),
+			Value:       cfg.DryRun,
+			EnvVars:     []string{"DRY_RUN"},
+		},
+		&cli.StringFlag{
+			Name:        "dry-run-output",
+			Usage:       "where dry run requests are written: a .ndjson file, a directory, or - for stdout, defaults to dry-run.ndjson in the scratch dir",
+			Destination: &(cfg.DryRunOutput),
+			Value:       cfg.DryRunOutput,
+			EnvVars:     []string{"DRY_RUN_OUTPUT"},
+		},
 	}
 
 	return cfg, flags
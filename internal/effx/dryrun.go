@@ -0,0 +1,136 @@
+package effx
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/url"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+	"sync"
+)
+
+// dryRunWriter records sync requests instead of sending them to the api.
+type dryRunWriter interface {
+	Write(syncRequest *SyncRequest) error
+	Delete(deleteRequest *DeleteRequest) error
+	Close() error
+}
+
+func newDryRunWriter(output string) (dryRunWriter, error) {
+	if output == "-" {
+		return &ndjsonWriter{out: os.Stdout}, nil
+	}
+
+	if strings.HasSuffix(output, ".ndjson") {
+		if err := os.MkdirAll(filepath.Dir(output), 0755); err != nil {
+			return nil, err
+		}
+
+		file, err := os.OpenFile(output, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, 0644)
+		if err != nil {
+			return nil, err
+		}
+		return &ndjsonWriter{out: file, closer: file}, nil
+	}
+
+	if err := os.MkdirAll(output, 0755); err != nil {
+		return nil, err
+	}
+	return &dirWriter{dir: output}, nil
+}
+
+// ndjsonWriter writes each request as a single line of JSON.
+type ndjsonWriter struct {
+	mu  sync.Mutex
+	out io.Writer
+	// closer is nil when writing to stdout
+	closer io.Closer
+}
+
+func (w *ndjsonWriter) Write(syncRequest *SyncRequest) error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	return json.NewEncoder(w.out).Encode(syncRequest)
+}
+
+func (w *ndjsonWriter) Delete(deleteRequest *DeleteRequest) error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	return json.NewEncoder(w.out).Encode(struct {
+		Delete *DeleteRequest `json:"delete"`
+	}{deleteRequest})
+}
+
+func (w *ndjsonWriter) Close() error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	if w.closer == nil {
+		return nil
+	}
+	return w.closer.Close()
+}
+
+// dirWriter writes each request to its own file, mirroring the repository layout
+// so the output of two runs can be compared with diff.
+type dirWriter struct {
+	dir string
+}
+
+// requestPath returns where the request is written relative to the output
+// directory, for example github.com/org/repo/services/api/effx.yaml.json
+func requestPath(annotations map[string]string, suffix string) (string, error) {
+	repository := annotations["effx.io/repository"]
+	filePath := annotations["effx.io/file-path"]
+	if repository == "" || filePath == "" {
+		return "", fmt.Errorf("request is missing repository annotations")
+	}
+
+	if u, err := url.Parse(repository); err == nil && u.Host != "" {
+		repository = path.Join(u.Host, u.Path)
+	}
+	repository = strings.TrimSuffix(strings.TrimPrefix(repository, "/"), ".git")
+
+	// cleaning a rooted path ensures the result never escapes the output directory
+	return path.Clean("/" + path.Join(repository, filePath+suffix))[1:], nil
+}
+
+func (w *dirWriter) Write(syncRequest *SyncRequest) error {
+	return w.write(syncRequest.Annotations, ".json", syncRequest)
+}
+
+// Delete writes the request next to where the synced file would be, for example
+// github.com/org/repo/services/api/effx.yaml.deleted.json
+func (w *dirWriter) Delete(deleteRequest *DeleteRequest) error {
+	return w.write(deleteRequest.Annotations, ".deleted.json", deleteRequest)
+}
+
+// Close has nothing to do, as each request is written to a file closed right away.
+func (w *dirWriter) Close() error {
+	return nil
+}
+
+func (w *dirWriter) write(annotations map[string]string, suffix string, request interface{}) error {
+	relative, err := requestPath(annotations, suffix)
+	if err != nil {
+		return err
+	}
+
+	target := filepath.Join(w.dir, filepath.FromSlash(relative))
+	if err := os.MkdirAll(filepath.Dir(target), 0755); err != nil {
+		return err
+	}
+
+	body, err := json.MarshalIndent(request, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	return ioutil.WriteFile(target, append(body, '\n'), 0644)
+}
@@ -0,0 +1,71 @@
+package effx_test
+
+import (
+	"context"
+	"encoding/json"
+	"io/ioutil"
+	"path"
+	"strings"
+	"testing"
+
+	"github.com/effxhq/vcs-connect/internal/effx"
+
+	"github.com/stretchr/testify/require"
+)
+
+func syncRequest(filePath string) *effx.SyncRequest {
+	return &effx.SyncRequest{
+		FileContents: "version: effx/v1\n",
+		Tags:         map[string]string{"owner": "effxhq"},
+		Annotations: map[string]string{
+			"effx.io/repository": "https://github.com/effxhq/vcs-connect.git",
+			"effx.io/file-path":  filePath,
+		},
+	}
+}
+
+func TestClient_DryRunDirectory(t *testing.T) {
+	dir := t.TempDir()
+
+	client, err := effx.New(&effx.Configuration{DryRun: true, DryRunOutput: dir})
+	require.NoError(t, err)
+	require.True(t, client.IsDryRun())
+
+	require.NoError(t, client.Sync(context.Background(), syncRequest("services/api/effx.yaml")))
+	require.NoError(t, client.Sync(context.Background(), syncRequest("../../../../escape/effx.yaml")))
+
+	body, err := ioutil.ReadFile(path.Join(dir, "github.com/effxhq/vcs-connect/services/api/effx.yaml.json"))
+	require.NoError(t, err)
+
+	written := &effx.SyncRequest{}
+	require.NoError(t, json.Unmarshal(body, written))
+	require.Equal(t, "version: effx/v1\n", written.FileContents)
+	require.Equal(t, "effxhq", written.Tags["owner"])
+
+	// paths are kept within the output directory
+	_, err = ioutil.ReadFile(path.Join(dir, "escape/effx.yaml.json"))
+	require.NoError(t, err)
+}
+
+func TestClient_DryRunNDJSON(t *testing.T) {
+	// missing parent directories are created
+	file := path.Join(t.TempDir(), "dry-run", "requests.ndjson")
+
+	client, err := effx.New(&effx.Configuration{DryRun: true, DryRunOutput: file})
+	require.NoError(t, err)
+
+	require.NoError(t, client.Sync(context.Background(), syncRequest("effx.yaml")))
+	require.NoError(t, client.Sync(context.Background(), syncRequest("other.effx.yaml")))
+	require.NoError(t, client.DetectServices(context.Background(), t.TempDir()))
+	require.NoError(t, client.Close())
+
+	// the file is closed, so later writes fail instead of being lost
+	require.Error(t, client.Sync(context.Background(), syncRequest("late.effx.yaml")))
+
+	body, err := ioutil.ReadFile(file)
+	require.NoError(t, err)
+
+	lines := strings.Split(strings.TrimSpace(string(body)), "\n")
+	require.Len(t, lines, 2)
+	require.Contains(t, lines[1], "other.effx.yaml")
+}
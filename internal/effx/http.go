@@ -2,12 +2,18 @@ package effx
 
 import (
 	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
 	"encoding/json"
 	"fmt"
 	"net/http"
 	"net/url"
+	"strconv"
 
 	"github.com/effxhq/effx-cli/discover"
+	"github.com/effxhq/vcs-connect/internal/metrics"
+	"github.com/effxhq/vcs-connect/internal/tracing"
 
 	"github.com/thoas/go-funk"
 )
@@ -18,7 +24,16 @@ func New(cfg *Configuration) (*Client, error) {
 		return nil, err
 	}
 
-	return &Client{cfg}, nil
+	client := &Client{cfg: cfg}
+	if cfg.DryRun {
+		writer, err := newDryRunWriter(cfg.DryRunOutput)
+		if err != nil {
+			return nil, fmt.Errorf("failed to setup dry run output: %v", err)
+		}
+		client.dryRun = writer
+	}
+
+	return client, nil
 }
 
 // SyncError contains information provided when an error occurs
@@ -33,9 +48,48 @@ type SyncRequest struct {
 	Annotations  map[string]string `json:"annotations,omitempty"`
 }
 
+// Hash returns a digest of the request's contents, tags and annotations, used
+// to detect when a file would be synced with the same request as before.
+func (r *SyncRequest) Hash() string {
+	// map keys are encoded in sorted order, so equal requests always encode the same way
+	body, _ := json.Marshal(r)
+	sum := sha256.Sum256(body)
+	return hex.EncodeToString(sum[:])
+}
+
+// DeleteRequest identifies a previously synced config blob by the annotations it was synced with.
+type DeleteRequest struct {
+	Annotations map[string]string `json:"annotations"`
+}
+
+// NewDeleteRequest returns a DeleteRequest for the effx.yaml file synced from the repository.
+func NewDeleteRequest(repository, filePath string) *DeleteRequest {
+	return &DeleteRequest{
+		Annotations: map[string]string{
+			"effx.io/source":     "vcs-connect",
+			"effx.io/repository": repository,
+			"effx.io/file-path":  filePath,
+		},
+	}
+}
+
 // Client encapsulates communication with the API.
 type Client struct {
-	cfg *Configuration
+	cfg    *Configuration
+	dryRun dryRunWriter
+}
+
+// IsDryRun returns true when requests are written locally instead of being sent to the api.
+func (c *Client) IsDryRun() bool {
+	return c.dryRun != nil
+}
+
+// Close releases the dry run output. The client must not be used afterwards.
+func (c *Client) Close() error {
+	if c.dryRun == nil {
+		return nil
+	}
+	return c.dryRun.Close()
 }
 
 // IsFeatureDisabled returns if a given feature is disabled.
@@ -45,21 +99,61 @@ func (c *Client) IsFeatureDisabled(feature string) bool {
 }
 
 // Sync attempts to synchronize provided contents with the upstream api.
-func (c *Client) Sync(syncRequest *SyncRequest) error {
+func (c *Client) Sync(ctx context.Context, syncRequest *SyncRequest) (err error) {
+	ctx, span := tracing.Start(ctx, "effx.Sync")
+	defer func() { tracing.End(span, err) }()
+
+	if c.dryRun != nil {
+		return c.dryRun.Write(syncRequest)
+	}
+
 	body, err := json.Marshal(syncRequest)
 	if err != nil {
 		return err
 	}
 
-	reader := bytes.NewReader(body)
+	return c.put(ctx, body)
+}
+
+// tombstone is synced in place of a deleted effx.yaml file. Unlike a SyncRequest,
+// the empty file contents are sent rather than omitted.
+type tombstone struct {
+	FileContents string            `json:"fileContents"`
+	Annotations  map[string]string `json:"annotations"`
+}
+
+// Delete replaces the config blob previously synced from an effx.yaml file with
+// an empty one, annotated as a tombstone.
+func (c *Client) Delete(ctx context.Context, deleteRequest *DeleteRequest) (err error) {
+	ctx, span := tracing.Start(ctx, "effx.Delete")
+	defer func() { tracing.End(span, err) }()
+
+	if c.dryRun != nil {
+		return c.dryRun.Delete(deleteRequest)
+	}
+
+	annotations := map[string]string{"effx.io/tombstone": "true"}
+	for k, v := range deleteRequest.Annotations {
+		annotations[k] = v
+	}
+
+	body, err := json.Marshal(&tombstone{Annotations: annotations})
+	if err != nil {
+		return err
+	}
+
+	return c.put(ctx, body)
+}
 
+// put sends the encoded config blob to the api.
+func (c *Client) put(ctx context.Context, body []byte) error {
 	endpoint := url.URL{
 		Scheme: "https",
 		Host:   c.cfg.APIHost,
 		Path:   "/v2/config",
 	}
 
-	req, err := http.NewRequest("PUT", endpoint.String(), reader)
+	req, err := http.NewRequestWithContext(ctx, "PUT", endpoint.String(), bytes.NewReader(body))
 	if err != nil {
 		return err
 	}
@@ -68,10 +162,13 @@ func (c *Client) Sync(syncRequest *SyncRequest) error {
 
 	resp, err := http.DefaultClient.Do(req)
 	if err != nil {
+		metrics.SyncRequests.WithLabelValues("error").Inc()
 		return err
 	}
 	defer resp.Body.Close()
 
+	metrics.SyncRequests.WithLabelValues(strconv.Itoa(resp.StatusCode)).Inc()
+
 	if resp.StatusCode != http.StatusNoContent {
 		syncErr := &SyncError{}
 		err = json.NewDecoder(resp.Body).Decode(syncErr)
@@ -86,6 +183,14 @@ func (c *Client) Sync(syncRequest *SyncRequest) error {
 }
 
 // DetectServices attempts to detect services based on repo work dir.
-func (c *Client) DetectServices(workDir string) error {
+func (c *Client) DetectServices(ctx context.Context, workDir string) (err error) {
+	_, span := tracing.Start(ctx, "effx.DetectServices")
+	defer func() { tracing.End(span, err) }()
+
+	// detected services are sent directly to the api, so there is nothing to write
+	if c.dryRun != nil {
+		return nil
+	}
+
 	return discover.DetectServicesFromWorkDir(workDir, c.cfg.APIKey, "vcs-connect")
 }
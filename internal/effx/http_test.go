@@ -0,0 +1,122 @@
+package effx_test
+
+import (
+	"context"
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/effxhq/vcs-connect/internal/effx"
+	"github.com/effxhq/vcs-connect/internal/metrics"
+
+	"github.com/prometheus/client_golang/prometheus/testutil"
+
+	"github.com/stretchr/testify/require"
+)
+
+// received records a request sent to the test server.
+type received struct {
+	method string
+	path   string
+	apiKey string
+	body   []byte
+}
+
+// serve points the client at a TLS server answering with the provided status,
+// returning the requests it received.
+func serve(t *testing.T, status int) (*effx.Client, func() []*received) {
+	var (
+		mu       sync.Mutex
+		requests []*received
+	)
+
+	server := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := ioutil.ReadAll(r.Body)
+
+		mu.Lock()
+		requests = append(requests, &received{
+			method: r.Method,
+			path:   r.URL.Path,
+			apiKey: r.Header.Get("x-effx-api-key"),
+			body:   body,
+		})
+		mu.Unlock()
+
+		w.WriteHeader(status)
+		if status >= http.StatusBadRequest {
+			w.Write([]byte(`{"message": "something went wrong"}`))
+		}
+	}))
+	t.Cleanup(server.Close)
+
+	// requests are sent with the default client, which must trust the test server
+	defaultClient := http.DefaultClient
+	http.DefaultClient = server.Client()
+	t.Cleanup(func() { http.DefaultClient = defaultClient })
+
+	client, err := effx.New(&effx.Configuration{
+		APIKey:  "api_key",
+		APIHost: strings.TrimPrefix(server.URL, "https://"),
+	})
+	require.NoError(t, err)
+
+	return client, func() []*received {
+		mu.Lock()
+		defer mu.Unlock()
+		return requests
+	}
+}
+
+func TestClient_Delete(t *testing.T) {
+	client, requests := serve(t, http.StatusNoContent)
+
+	before := testutil.ToFloat64(metrics.SyncRequests.WithLabelValues("204"))
+
+	err := client.Delete(context.Background(), effx.NewDeleteRequest("https://github.com/effxhq/vcs-connect.git", "services/api/effx.yaml"))
+	require.NoError(t, err)
+
+	// deleted files are synced as empty tombstones
+	sent := requests()
+	require.Len(t, sent, 1)
+	require.Equal(t, "PUT", sent[0].method)
+	require.Equal(t, "/v2/config", sent[0].path)
+	require.Equal(t, "api_key", sent[0].apiKey)
+
+	body := map[string]interface{}{}
+	require.NoError(t, json.Unmarshal(sent[0].body, &body))
+	require.Equal(t, map[string]interface{}{
+		"fileContents": "",
+		"annotations": map[string]interface{}{
+			"effx.io/source":     "vcs-connect",
+			"effx.io/repository": "https://github.com/effxhq/vcs-connect.git",
+			"effx.io/file-path":  "services/api/effx.yaml",
+			"effx.io/tombstone":  "true",
+		},
+	}, body)
+
+	require.Equal(t, before+1, testutil.ToFloat64(metrics.SyncRequests.WithLabelValues("204")))
+}
+
+func TestClient_DeleteMissing(t *testing.T) {
+	client, requests := serve(t, http.StatusNotFound)
+
+	// only a synced tombstone counts as deleted
+	err := client.Delete(context.Background(), effx.NewDeleteRequest("https://github.com/effxhq/vcs-connect.git", "effx.yaml"))
+	require.EqualError(t, err, "something went wrong")
+	require.Len(t, requests(), 1)
+}
+
+func TestClient_DeleteError(t *testing.T) {
+	client, _ := serve(t, http.StatusInternalServerError)
+
+	before := testutil.ToFloat64(metrics.SyncRequests.WithLabelValues("500"))
+
+	err := client.Delete(context.Background(), effx.NewDeleteRequest("https://github.com/effxhq/vcs-connect.git", "effx.yaml"))
+	require.EqualError(t, err, "something went wrong")
+
+	require.Equal(t, before+1, testutil.ToFloat64(metrics.SyncRequests.WithLabelValues("500")))
+}
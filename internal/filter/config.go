@@ -0,0 +1,148 @@
+package filter
+
+import (
+	"fmt"
+
+	"github.com/urfave/cli/v2"
+)
+
+// Properties is a set of repository properties reported by a host.
+type Properties uint
+
+// Repository properties that filters rely on.
+const (
+	Archived Properties = 1 << iota
+	Forks
+	Templates
+	Mirrors
+	Empty
+	Visibility
+	Topics
+)
+
+// Configuration encapsulates the rules used to select which discovered
+// repositories are indexed.
+type Configuration struct {
+	Include          *cli.StringSlice
+	Exclude          *cli.StringSlice
+	ExcludeArchived  bool
+	ExcludeForks     bool
+	ExcludeTemplates bool
+	ExcludeMirrors   bool
+	ExcludeEmpty     bool
+	Visibility       *cli.StringSlice
+	Topics           *cli.StringSlice
+	ExcludeTopics    *cli.StringSlice
+}
+
+// Supported ensures the configured filters only rely on properties reported by
+// the host, as filters would otherwise silently have no effect or exclude every
+// repository.
+func (c *Configuration) Supported(host string, reported Properties) error {
+	rules := []struct {
+		enabled  bool
+		property Properties
+		name     string
+	}{
+		{c.ExcludeArchived, Archived, "excluding archived repositories"},
+		{c.ExcludeForks, Forks, "excluding forks"},
+		{c.ExcludeTemplates, Templates, "excluding templates"},
+		{c.ExcludeMirrors, Mirrors, "excluding mirrors"},
+		{c.ExcludeEmpty, Empty, "excluding empty repositories"},
+		{len(c.Visibility.Value()) > 0, Visibility, "filtering by visibility"},
+		{len(c.Topics.Value()) > 0 || len(c.ExcludeTopics.Value()) > 0, Topics, "filtering by topics"},
+	}
+
+	for _, rule := range rules {
+		if rule.enabled && reported&rule.property == 0 {
+			return fmt.Errorf("%s is not supported for %s", rule.name, host)
+		}
+	}
+	return nil
+}
+
+// DefaultConfigWithFlags returns configuration and flags specific to repository filtering.
+func DefaultConfigWithFlags() (*Configuration, []cli.Flag) {
+	cfg := &Configuration{
+		Include:       cli.NewStringSlice(),
+		Exclude:       cli.NewStringSlice(),
+		Visibility:    cli.NewStringSlice(),
+		Topics:        cli.NewStringSlice(),
+		ExcludeTopics: cli.NewStringSlice(),
+	}
+
+	flags := []cli.Flag{
+		&cli.StringSliceFlag{
+			Name:        "include-repositories",
+			Usage:       "only index repositories whose full name matches one of these regular expressions",
+			Destination: cfg.Include,
+			Value:       cfg.Include,
+			EnvVars:     []string{"INCLUDE_REPOSITORIES"},
+		},
+		&cli.StringSliceFlag{
+			Name:        "exclude-repositories",
+			Usage:       "skip repositories whose full name matches one of these regular expressions",
+			Destination: cfg.Exclude,
+			Value:       cfg.Exclude,
+			EnvVars:     []string{"EXCLUDE_REPOSITORIES"},
+		},
+		&cli.BoolFlag{
+			Name:        "exclude-archived",
+			Usage:       "skip archived repositories",
+			Destination: &(cfg.ExcludeArchived),
+			Value:       cfg.ExcludeArchived,
+			EnvVars:     []string{"EXCLUDE_ARCHIVED"},
+		},
+		&cli.BoolFlag{
+			Name:        "exclude-forks",
+			Usage:       "skip repositories that are forks",
+			Destination: &(cfg.ExcludeForks),
+			Value:       cfg.ExcludeForks,
+			EnvVars:     []string{"EXCLUDE_FORKS"},
+		},
+		&cli.BoolFlag{
+			Name:        "exclude-templates",
+			Usage:       "skip template repositories",
+			Destination: &(cfg.ExcludeTemplates),
+			Value:       cfg.ExcludeTemplates,
+			EnvVars:     []string{"EXCLUDE_TEMPLATES"},
+		},
+		&cli.BoolFlag{
+			Name:        "exclude-mirrors",
+			Usage:       "skip repositories that mirror another remote",
+			Destination: &(cfg.ExcludeMirrors),
+			Value:       cfg.ExcludeMirrors,
+			EnvVars:     []string{"EXCLUDE_MIRRORS"},
+		},
+		&cli.BoolFlag{
+			Name:        "exclude-empty",
+			Usage:       "skip repositories without any commits",
+			Destination: &(cfg.ExcludeEmpty),
+			Value:       cfg.ExcludeEmpty,
+			EnvVars:     []string{"EXCLUDE_EMPTY"},
+		},
+		&cli.StringSliceFlag{
+			Name:        "visibility",
+			Usage:       "only index repositories with one of these visibilities (public, internal, private)",
+			Destination: cfg.Visibility,
+			Value:       cfg.Visibility,
+			EnvVars:     []string{"VISIBILITY"},
+		},
+		&cli.StringSliceFlag{
+			Name:        "topics",
+			Usage:       "only index repositories labeled with at least one of these topics",
+			Destination: cfg.Topics,
+			Value:       cfg.Topics,
+			EnvVars:     []string{"TOPICS"},
+		},
+		&cli.StringSliceFlag{
+			Name:        "exclude-topics",
+			Usage:       "skip repositories labeled with any of these topics",
+			Destination: cfg.ExcludeTopics,
+			Value:       cfg.ExcludeTopics,
+			EnvVars:     []string{"EXCLUDE_TOPICS"},
+		},
+	}
+
+	return cfg, flags
+}
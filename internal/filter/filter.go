@@ -0,0 +1,109 @@
+package filter
+
+import (
+	"regexp"
+	"strings"
+
+	"github.com/effxhq/vcs-connect/internal/model"
+
+	"github.com/pkg/errors"
+
+	"github.com/thoas/go-funk"
+)
+
+func compile(patterns []string) ([]*regexp.Regexp, error) {
+	compiled := make([]*regexp.Regexp, len(patterns))
+	for i, pattern := range patterns {
+		re, err := regexp.Compile(pattern)
+		if err != nil {
+			return nil, errors.Wrapf(err, "invalid repository pattern %s", pattern)
+		}
+		compiled[i] = re
+	}
+	return compiled, nil
+}
+
+func matchesAny(patterns []*regexp.Regexp, value string) bool {
+	for _, pattern := range patterns {
+		if pattern.MatchString(value) {
+			return true
+		}
+	}
+	return false
+}
+
+func lower(values []string) []string {
+	out := make([]string, len(values))
+	for i, value := range values {
+		out[i] = strings.ToLower(value)
+	}
+	return out
+}
+
+// New returns a Filter applying the rules of the provided configuration.
+func New(cfg *Configuration) (*Filter, error) {
+	include, err := compile(cfg.Include.Value())
+	if err != nil {
+		return nil, err
+	}
+
+	exclude, err := compile(cfg.Exclude.Value())
+	if err != nil {
+		return nil, err
+	}
+
+	return &Filter{
+		cfg:           cfg,
+		include:       include,
+		exclude:       exclude,
+		visibility:    lower(cfg.Visibility.Value()),
+		topics:        lower(cfg.Topics.Value()),
+		excludeTopics: lower(cfg.ExcludeTopics.Value()),
+	}, nil
+}
+
+// Filter decides which repositories discovered by an integration are indexed.
+type Filter struct {
+	cfg           *Configuration
+	include       []*regexp.Regexp
+	exclude       []*regexp.Regexp
+	visibility    []string
+	topics        []string
+	excludeTopics []string
+}
+
+// Reason returns why the repository is excluded, or an empty string when it should be indexed.
+func (f *Filter) Reason(repository *model.Repository) string {
+	switch {
+	case len(f.include) > 0 && !matchesAny(f.include, repository.FullName):
+		return "name is not included"
+	case matchesAny(f.exclude, repository.FullName):
+		return "name is excluded"
+	case f.cfg.ExcludeArchived && repository.Archived:
+		return "repository is archived"
+	case f.cfg.ExcludeForks && repository.Fork:
+		return "repository is a fork"
+	case f.cfg.ExcludeTemplates && repository.Template:
+		return "repository is a template"
+	case f.cfg.ExcludeMirrors && repository.Mirror:
+		return "repository is a mirror"
+	case f.cfg.ExcludeEmpty && repository.Empty:
+		return "repository is empty"
+	case len(f.visibility) > 0 && !funk.ContainsString(f.visibility, strings.ToLower(repository.Visibility)):
+		return "visibility is not included"
+	}
+
+	topics := lower(repository.Topics)
+	if len(f.topics) > 0 && len(funk.IntersectString(f.topics, topics)) == 0 {
+		return "topics are not included"
+	} else if len(funk.IntersectString(f.excludeTopics, topics)) > 0 {
+		return "topic is excluded"
+	}
+
+	return ""
+}
+
+// Matches returns true when the repository should be indexed.
+func (f *Filter) Matches(repository *model.Repository) bool {
+	return f.Reason(repository) == ""
+}
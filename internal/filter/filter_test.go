@@ -0,0 +1,112 @@
+package filter_test
+
+import (
+	"testing"
+
+	"github.com/effxhq/vcs-connect/internal/filter"
+	"github.com/effxhq/vcs-connect/internal/model"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestFilter_Defaults(t *testing.T) {
+	cfg, _ := filter.DefaultConfigWithFlags()
+
+	f, err := filter.New(cfg)
+	require.NoError(t, err)
+
+	require.True(t, f.Matches(&model.Repository{
+		FullName: "effxhq/vcs-connect",
+		Archived: true,
+		Fork:     true,
+		Template: true,
+		Mirror:   true,
+		Empty:    true,
+	}))
+}
+
+func TestFilter_Names(t *testing.T) {
+	cfg, _ := filter.DefaultConfigWithFlags()
+	require.NoError(t, cfg.Include.Set("^effxhq/"))
+	require.NoError(t, cfg.Exclude.Set("-archive$"))
+
+	f, err := filter.New(cfg)
+	require.NoError(t, err)
+
+	require.True(t, f.Matches(&model.Repository{FullName: "effxhq/vcs-connect"}))
+	require.False(t, f.Matches(&model.Repository{FullName: "other/vcs-connect"}))
+	require.False(t, f.Matches(&model.Repository{FullName: "effxhq/vcs-connect-archive"}))
+}
+
+func TestFilter_InvalidPattern(t *testing.T) {
+	cfg, _ := filter.DefaultConfigWithFlags()
+	require.NoError(t, cfg.Exclude.Set("("))
+
+	_, err := filter.New(cfg)
+	require.Error(t, err)
+}
+
+func TestFilter_Toggles(t *testing.T) {
+	cfg, _ := filter.DefaultConfigWithFlags()
+	cfg.ExcludeArchived = true
+	cfg.ExcludeForks = true
+	cfg.ExcludeTemplates = true
+	cfg.ExcludeMirrors = true
+	cfg.ExcludeEmpty = true
+
+	f, err := filter.New(cfg)
+	require.NoError(t, err)
+
+	require.True(t, f.Matches(&model.Repository{FullName: "effxhq/vcs-connect"}))
+	require.Equal(t, "repository is archived", f.Reason(&model.Repository{Archived: true}))
+	require.Equal(t, "repository is a fork", f.Reason(&model.Repository{Fork: true}))
+	require.Equal(t, "repository is a template", f.Reason(&model.Repository{Template: true}))
+	require.Equal(t, "repository is a mirror", f.Reason(&model.Repository{Mirror: true}))
+	require.Equal(t, "repository is empty", f.Reason(&model.Repository{Empty: true}))
+}
+
+func TestFilter_Visibility(t *testing.T) {
+	cfg, _ := filter.DefaultConfigWithFlags()
+	require.NoError(t, cfg.Visibility.Set("Internal"))
+	require.NoError(t, cfg.Visibility.Set("private"))
+
+	f, err := filter.New(cfg)
+	require.NoError(t, err)
+
+	require.True(t, f.Matches(&model.Repository{Visibility: "internal"}))
+	require.True(t, f.Matches(&model.Repository{Visibility: "private"}))
+	require.False(t, f.Matches(&model.Repository{Visibility: "public"}))
+}
+
+func TestFilter_Topics(t *testing.T) {
+	cfg, _ := filter.DefaultConfigWithFlags()
+	require.NoError(t, cfg.Topics.Set("service"))
+	require.NoError(t, cfg.ExcludeTopics.Set("deprecated"))
+
+	f, err := filter.New(cfg)
+	require.NoError(t, err)
+
+	require.True(t, f.Matches(&model.Repository{Topics: []string{"Service", "go"}}))
+	require.False(t, f.Matches(&model.Repository{Topics: []string{"library"}}))
+	require.False(t, f.Matches(&model.Repository{Topics: []string{"service", "deprecated"}}))
+}
+
+func TestConfiguration_Supported(t *testing.T) {
+	cfg, _ := filter.DefaultConfigWithFlags()
+	require.NoError(t, cfg.Supported("GitLab", 0))
+
+	cfg.ExcludeTemplates = true
+	require.EqualError(t, cfg.Supported("GitLab", filter.Archived|filter.Forks|filter.Mirrors),
+		"excluding templates is not supported for GitLab")
+	require.NoError(t, cfg.Supported("Gitea", filter.Templates))
+
+	cfg, _ = filter.DefaultConfigWithFlags()
+	require.NoError(t, cfg.ExcludeTopics.Set("deprecated"))
+	require.EqualError(t, cfg.Supported("Gitea", filter.Visibility), "filtering by topics is not supported for Gitea")
+	require.NoError(t, cfg.Supported("GitHub", filter.Topics))
+
+	cfg, _ = filter.DefaultConfigWithFlags()
+	require.NoError(t, cfg.Visibility.Set("internal"))
+	require.EqualError(t, cfg.Supported("Azure DevOps", filter.Forks|filter.Empty),
+		"filtering by visibility is not supported for Azure DevOps")
+}
@@ -0,0 +1,59 @@
+package azuredevops
+
+import (
+	"fmt"
+
+	"github.com/urfave/cli/v2"
+)
+
+// Configuration encapsulates information needed for communicating with the
+// Azure DevOps REST API
+type Configuration struct {
+	BaseURL             string
+	PersonalAccessToken string
+	Organizations       *cli.StringSlice
+}
+
+// Validate ensures the configuration provided contains the required information.
+func (c *Configuration) Validate() error {
+	if c.BaseURL == "" {
+		return fmt.Errorf("a base url must be provided")
+	} else if c.PersonalAccessToken == "" {
+		return fmt.Errorf("a personal access token must be provided")
+	}
+	return nil
+}
+
+// DefaultConfigWithFlags returns configuration and flags specific to Azure DevOps
+func DefaultConfigWithFlags() (*Configuration, []cli.Flag) {
+	cfg := &Configuration{
+		BaseURL:       "https://dev.azure.com",
+		Organizations: cli.NewStringSlice(),
+	}
+
+	flags := []cli.Flag{
+		&cli.StringFlag{
+			Name:        "azure-devops-base-url",
+			Usage:       "url to the Azure DevOps instance",
+			Destination: &(cfg.BaseURL),
+			Value:       cfg.BaseURL,
+			EnvVars:     []string{"AZURE_DEVOPS_BASE_URL"},
+		},
+		&cli.StringFlag{
+			Name:        "azure-devops-access-token",
+			Usage:       "used to read data from the Azure DevOps API and clone repositories",
+			Destination: &(cfg.PersonalAccessToken),
+			Value:       cfg.PersonalAccessToken,
+			EnvVars:     []string{"AZURE_DEVOPS_ACCESS_TOKEN"},
+		},
+		&cli.StringSliceFlag{
+			Name:        "azure-devops-organizations",
+			Usage:       "restricts operations to listed Azure DevOps organizations",
+			Destination: cfg.Organizations,
+			Value:       cfg.Organizations,
+			EnvVars:     []string{"AZURE_DEVOPS_ORGANIZATIONS"},
+		},
+	}
+
+	return cfg, flags
+}
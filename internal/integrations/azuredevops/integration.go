@@ -0,0 +1,296 @@
+package azuredevops
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"github.com/effxhq/vcs-connect/internal/filter"
+	"github.com/effxhq/vcs-connect/internal/integrations"
+	"github.com/effxhq/vcs-connect/internal/logger"
+	"github.com/effxhq/vcs-connect/internal/metrics"
+	"github.com/effxhq/vcs-connect/internal/model"
+	"github.com/effxhq/vcs-connect/internal/tracing"
+
+	"github.com/pkg/errors"
+
+	"go.uber.org/zap"
+)
+
+const (
+	apiVersion = "6.0"
+
+	// profileBaseURL hosts the profile and accounts APIs used to discover organizations.
+	profileBaseURL = "https://app.vssps.visualstudio.com"
+
+	continuationTokenHeader = "x-ms-continuationtoken"
+)
+
+// NewIntegration returns the Integration responsible for communicating with Azure DevOps.
+// Before construction, the Configuration is validated to ensure it contains the
+// proper information.
+func NewIntegration(ctx context.Context, config *Configuration, filter integrations.Filter) (*Integration, error) {
+	if err := config.Validate(); err != nil {
+		return nil, err
+	}
+
+	return &Integration{
+		client: &http.Client{Transport: metrics.Transport("azuredevops", tracing.Transport(http.DefaultTransport))},
+		config: config,
+		filter: filter,
+	}, nil
+}
+
+// Integration encapsulates the logic for integrating data from Azure DevOps.
+type Integration struct {
+	client *http.Client
+	config *Configuration
+	filter integrations.Filter
+}
+
+type profile struct {
+	ID string `json:"id"`
+}
+
+type account struct {
+	AccountName string `json:"accountName"`
+}
+
+type project struct {
+	Name string `json:"name"`
+}
+
+// ReportedProperties are the properties Azure DevOps reports for repositories.
+// Visibility is a property of projects rather than repositories.
+const ReportedProperties = filter.Forks | filter.Empty
+
+type repository struct {
+	Name          string `json:"name"`
+	RemoteURL     string `json:"remoteUrl"`
+	SSHURL        string `json:"sshUrl"`
+	WebURL        string `json:"webUrl"`
+	DefaultBranch string `json:"defaultBranch"`
+	Size          int64  `json:"size"`
+	IsFork        bool   `json:"isFork"`
+	IsDisabled    bool   `json:"isDisabled"`
+}
+
+// cloneURL returns the remote url of the repository without the embedded organization user.
+func (r *repository) cloneURL() string {
+	u, err := url.Parse(r.RemoteURL)
+	if err != nil {
+		return r.RemoteURL
+	}
+	u.User = nil
+	return u.String()
+}
+
+// get performs an authenticated request against the REST API and decodes the response into out.
+// It returns the continuation token for the next page, or an empty string when there are no more pages.
+func (i *Integration) get(ctx context.Context, endpoint string, query url.Values, out interface{}) (string, error) {
+	query.Set("api-version", apiVersion)
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, endpoint+"?"+query.Encode(), nil)
+	if err != nil {
+		return "", err
+	}
+	req.SetBasicAuth("", i.config.PersonalAccessToken)
+	req.Header.Add("accept", "application/json")
+
+	resp, err := i.client.Do(req)
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("unexpected status code %d from %s", resp.StatusCode, endpoint)
+	}
+
+	if err := json.NewDecoder(resp.Body).Decode(out); err != nil {
+		return "", err
+	}
+
+	return resp.Header.Get(continuationTokenHeader), nil
+}
+
+func (i *Integration) discoverOrganizations(ctx context.Context) ([]string, error) {
+	configured := i.config.Organizations.Value()
+	if len(configured) > 0 {
+		return configured, nil
+	}
+
+	me := &profile{}
+	_, err := i.get(ctx, profileBaseURL+"/_apis/profile/profiles/me", url.Values{}, me)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to fetch profile")
+	}
+
+	accounts := struct {
+		Value []*account `json:"value"`
+	}{}
+
+	_, err = i.get(ctx, profileBaseURL+"/_apis/accounts", url.Values{"memberId": {me.ID}}, &accounts)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to list accounts")
+	}
+
+	organizations := make([]string, len(accounts.Value))
+	for i, acct := range accounts.Value {
+		organizations[i] = acct.AccountName
+	}
+
+	return organizations, nil
+}
+
+func (i *Integration) discoverProjects(ctx context.Context, organization string) ([]string, error) {
+	projects := make([]string, 0)
+	endpoint := fmt.Sprintf("%s/%s/_apis/projects",
+		strings.TrimSuffix(i.config.BaseURL, "/"), url.PathEscape(organization))
+
+	continuationToken := ""
+	for {
+		query := url.Values{"$top": {"100"}}
+		if continuationToken != "" {
+			query.Set("continuationToken", continuationToken)
+		}
+
+		page := struct {
+			Value []*project `json:"value"`
+		}{}
+
+		var err error
+		continuationToken, err = i.get(ctx, endpoint, query, &page)
+		if err != nil {
+			return nil, err
+		}
+
+		results := make([]string, len(page.Value))
+		for i, prj := range page.Value {
+			results[i] = prj.Name
+		}
+
+		projects = append(projects, results...)
+
+		if continuationToken == "" {
+			break
+		}
+	}
+
+	return projects, nil
+}
+
+func (i *Integration) discoverRepositories(ctx context.Context, organization, project string) ([]*model.Repository, error) {
+	repositories := make([]*model.Repository, 0)
+	endpoint := fmt.Sprintf("%s/%s/%s/_apis/git/repositories",
+		strings.TrimSuffix(i.config.BaseURL, "/"), url.PathEscape(organization), url.PathEscape(project))
+
+	continuationToken := ""
+	for {
+		query := url.Values{}
+		if continuationToken != "" {
+			query.Set("continuationToken", continuationToken)
+		}
+
+		page := struct {
+			Value []*repository `json:"value"`
+		}{}
+
+		var err error
+		continuationToken, err = i.get(ctx, endpoint, query, &page)
+		if err != nil {
+			return nil, err
+		}
+
+		for _, repo := range page.Value {
+			// disabled repositories cannot be cloned
+			if repo.IsDisabled {
+				continue
+			}
+
+			repositories = append(repositories, &model.Repository{
+				CloneURL: repo.cloneURL(),
+				SSHURL:   repo.SSHURL,
+				Tags: map[string]string{
+					"project": project,
+				},
+				Annotations:   map[string]string{},
+				FullName:      organization + "/" + project + "/" + repo.Name,
+				Owner:         project,
+				DefaultBranch: strings.TrimPrefix(repo.DefaultBranch, "refs/heads/"),
+				WebURL:        repo.WebURL,
+				Fork:          repo.IsFork,
+				Empty:         repo.Size == 0,
+			})
+		}
+
+		if continuationToken == "" {
+			break
+		}
+	}
+
+	return repositories, nil
+}
+
+// Run feeds the data channel with results it discovers from Azure DevOps
+func (i *Integration) Run(ctx context.Context, data chan *model.Repository) error {
+	log := logger.MustGetFromContext(ctx)
+
+	organizations, err := i.discoverOrganizations(ctx)
+	if err != nil {
+		return errors.Wrap(err, "failed to discover organizations from Azure DevOps")
+	}
+
+	for _, organization := range organizations {
+		log.Info("discovering projects",
+			zap.String("organization", organization))
+
+		projects, err := i.discoverProjects(ctx, organization)
+		if err != nil {
+			log.Error("failed to discover projects",
+				zap.String("organization", organization),
+				zap.Error(err))
+			continue
+		}
+
+		for _, project := range projects {
+			log.Info("discovering repositories",
+				zap.String("organization", organization),
+				zap.String("project", project))
+
+			repositories, err := i.discoverRepositories(ctx, organization, project)
+			if err != nil {
+				log.Error("failed to discover repositories",
+					zap.String("organization", organization),
+					zap.String("project", project),
+					zap.Error(err))
+				continue
+			}
+
+			// push to consumers or stop if cancelled
+			for _, repository := range repositories {
+				if reason := i.filter.Reason(repository); reason != "" {
+					log.Info("skipping repository",
+						zap.String("repository", repository.CloneURL),
+						zap.String("reason", reason))
+					continue
+				}
+
+				log.Info("processing repository",
+					zap.String("repository", repository.CloneURL))
+
+				select {
+				case <-ctx.Done():
+					return nil
+				case data <- repository:
+					continue
+				}
+			}
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,193 @@
+package azuredevops_test
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+
+	"github.com/effxhq/vcs-connect/internal/filter"
+	"github.com/effxhq/vcs-connect/internal/integrations/azuredevops"
+	"github.com/effxhq/vcs-connect/internal/logger"
+	"github.com/effxhq/vcs-connect/internal/model"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/urfave/cli/v2"
+
+	"go.uber.org/zap"
+)
+
+// page is a response of the Azure DevOps REST API, continued by the next token when set.
+type page struct {
+	body string
+	next string
+}
+
+// fakeAzureDevOps serves pages keyed by path and continuation token, and records
+// each request it receives.
+type fakeAzureDevOps struct {
+	pages map[string]page
+
+	mu       sync.Mutex
+	requests []string
+	tokens   []string
+}
+
+func (f *fakeAzureDevOps) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	key := r.URL.Path
+	if token := r.URL.Query().Get("continuationToken"); token != "" {
+		key += "?continuationToken=" + token
+	}
+
+	_, token, _ := r.BasicAuth()
+
+	f.mu.Lock()
+	f.requests = append(f.requests, key)
+	f.tokens = append(f.tokens, token)
+	f.mu.Unlock()
+
+	p, ok := f.pages[key]
+	if !ok {
+		w.WriteHeader(http.StatusNotFound)
+		return
+	}
+
+	if p.next != "" {
+		w.Header().Set("x-ms-continuationtoken", p.next)
+	}
+	fmt.Fprint(w, p.body)
+}
+
+func newFilter(t *testing.T) *filter.Filter {
+	cfg, _ := filter.DefaultConfigWithFlags()
+	repoFilter, err := filter.New(cfg)
+	require.NoError(t, err)
+	return repoFilter
+}
+
+func discover(t *testing.T, api *fakeAzureDevOps, organizations ...string) []*model.Repository {
+	server := httptest.NewServer(api)
+	defer server.Close()
+
+	ctx := logger.AttachToContext(context.Background(), zap.NewNop())
+
+	integration, err := azuredevops.NewIntegration(ctx, &azuredevops.Configuration{
+		BaseURL:             server.URL,
+		PersonalAccessToken: "personal_access_token",
+		Organizations:       cli.NewStringSlice(organizations...),
+	}, newFilter(t))
+	require.NoError(t, err)
+
+	data := make(chan *model.Repository, 10)
+	require.NoError(t, integration.Run(ctx, data))
+	close(data)
+
+	repositories := make([]*model.Repository, 0)
+	for repository := range data {
+		repositories = append(repositories, repository)
+	}
+	return repositories
+}
+
+func TestIntegration_Run(t *testing.T) {
+	api := &fakeAzureDevOps{pages: map[string]page{
+		"/effx/_apis/projects": {
+			body: `{"value": [{"name": "Platform"}]}`,
+			next: "projects-2",
+		},
+		"/effx/_apis/projects?continuationToken=projects-2": {
+			body: `{"value": [{"name": "Web Apps"}]}`,
+		},
+		"/effx/Platform/_apis/git/repositories": {
+			body: `{"value": [{"name": "api", "size": 1024, "defaultBranch": "refs/heads/main", "webUrl": "https://dev.azure.com/effx/Platform/_git/api", "remoteUrl": "https://effx@dev.azure.com/effx/Platform/_git/api"}]}`,
+			next: "repositories-2",
+		},
+		"/effx/Platform/_apis/git/repositories?continuationToken=repositories-2": {
+			body: `{"value": [
+				{"name": "legacy", "size": 1024, "remoteUrl": "https://effx@dev.azure.com/effx/Platform/_git/legacy", "isDisabled": true},
+				{"name": "tools", "size": 1024, "isFork": true, "remoteUrl": "https://effx@dev.azure.com/effx/Platform/_git/tools"}
+			]}`,
+		},
+		"/effx/Web Apps/_apis/git/repositories": {
+			body: `{"value": [{"name": "site", "size": 1024, "remoteUrl": "https://effx@dev.azure.com/effx/Web%20Apps/_git/site"}]}`,
+		},
+		"/ops/_apis/projects": {
+			body: `{"value": [{"name": "Tooling"}]}`,
+		},
+		"/ops/Tooling/_apis/git/repositories": {
+			body: `{"value": [{"name": "scripts", "size": 0, "remoteUrl": "https://ops@dev.azure.com/ops/Tooling/_git/scripts"}]}`,
+		},
+	}}
+
+	repositories := discover(t, api, "effx", "ops")
+
+	// disabled repositories are skipped, and each repository is tagged with its project
+	require.Equal(t, []*model.Repository{
+		{
+			CloneURL:      "https://dev.azure.com/effx/Platform/_git/api",
+			Tags:          map[string]string{"project": "Platform"},
+			Annotations:   map[string]string{},
+			FullName:      "effx/Platform/api",
+			Owner:         "Platform",
+			DefaultBranch: "main",
+			WebURL:        "https://dev.azure.com/effx/Platform/_git/api",
+		},
+		{
+			CloneURL:    "https://dev.azure.com/effx/Platform/_git/tools",
+			Tags:        map[string]string{"project": "Platform"},
+			Annotations: map[string]string{},
+			FullName:    "effx/Platform/tools",
+			Owner:       "Platform",
+			Fork:        true,
+		},
+		{
+			CloneURL:    "https://dev.azure.com/effx/Web%20Apps/_git/site",
+			Tags:        map[string]string{"project": "Web Apps"},
+			Annotations: map[string]string{},
+			FullName:    "effx/Web Apps/site",
+			Owner:       "Web Apps",
+		},
+		{
+			CloneURL:    "https://dev.azure.com/ops/Tooling/_git/scripts",
+			Tags:        map[string]string{"project": "Tooling"},
+			Annotations: map[string]string{},
+			FullName:    "ops/Tooling/scripts",
+			Owner:       "Tooling",
+			Empty:       true,
+		},
+	}, repositories)
+
+	// both projects and repositories are followed through their continuation tokens
+	require.Equal(t, []string{
+		"/effx/_apis/projects",
+		"/effx/_apis/projects?continuationToken=projects-2",
+		"/effx/Platform/_apis/git/repositories",
+		"/effx/Platform/_apis/git/repositories?continuationToken=repositories-2",
+		"/effx/Web Apps/_apis/git/repositories",
+		"/ops/_apis/projects",
+		"/ops/Tooling/_apis/git/repositories",
+	}, api.requests)
+
+	for _, token := range api.tokens {
+		require.Equal(t, "personal_access_token", token)
+	}
+}
+
+func TestIntegration_RunErrors(t *testing.T) {
+	api := &fakeAzureDevOps{pages: map[string]page{
+		"/effx/_apis/projects": {
+			body: `{"value": [{"name": "Broken"}, {"name": "Platform"}]}`,
+		},
+		"/effx/Platform/_apis/git/repositories": {
+			body: `{"value": [{"remoteUrl": "https://dev.azure.com/effx/Platform/_git/api"}]}`,
+		},
+	}}
+
+	// organizations and projects that fail to list are skipped
+	repositories := discover(t, api, "missing", "effx")
+	require.Len(t, repositories, 1)
+	require.Equal(t, "https://dev.azure.com/effx/Platform/_git/api", repositories[0].CloneURL)
+}
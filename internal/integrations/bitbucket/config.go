@@ -0,0 +1,69 @@
+package bitbucket
+
+import (
+	"fmt"
+
+	"github.com/urfave/cli/v2"
+)
+
+// Configuration encapsulates information needed for communicating with the
+// Bitbucket Cloud API
+type Configuration struct {
+	BaseURL     string
+	UserName    string
+	AppPassword string
+	Workspaces  *cli.StringSlice
+}
+
+// Validate ensures the configuration provided contains the required information.
+func (c *Configuration) Validate() error {
+	if c.BaseURL == "" {
+		return fmt.Errorf("a base url must be provided")
+	} else if c.UserName == "" {
+		return fmt.Errorf("a username must be provided")
+	} else if c.AppPassword == "" {
+		return fmt.Errorf("an app password must be provided")
+	}
+	return nil
+}
+
+// DefaultConfigWithFlags returns configuration and flags specific to Bitbucket Cloud
+func DefaultConfigWithFlags() (*Configuration, []cli.Flag) {
+	cfg := &Configuration{
+		BaseURL:    "https://api.bitbucket.org/2.0",
+		Workspaces: cli.NewStringSlice(),
+	}
+
+	flags := []cli.Flag{
+		&cli.StringFlag{
+			Name:        "bitbucket-base-url",
+			Usage:       "url to the Bitbucket Cloud API",
+			Destination: &(cfg.BaseURL),
+			Value:       cfg.BaseURL,
+			EnvVars:     []string{"BITBUCKET_BASE_URL"},
+		},
+		&cli.StringFlag{
+			Name:        "bitbucket-username",
+			Usage:       "the user associated with the app password",
+			Destination: &(cfg.UserName),
+			Value:       cfg.UserName,
+			EnvVars:     []string{"BITBUCKET_USERNAME"},
+		},
+		&cli.StringFlag{
+			Name:        "bitbucket-app-password",
+			Usage:       "used to read data from the Bitbucket API and clone repositories",
+			Destination: &(cfg.AppPassword),
+			Value:       cfg.AppPassword,
+			EnvVars:     []string{"BITBUCKET_APP_PASSWORD"},
+		},
+		&cli.StringSliceFlag{
+			Name:        "bitbucket-workspaces",
+			Usage:       "restricts operations to listed Bitbucket workspaces",
+			Destination: cfg.Workspaces,
+			Value:       cfg.Workspaces,
+			EnvVars:     []string{"BITBUCKET_WORKSPACES"},
+		},
+	}
+
+	return cfg, flags
+}
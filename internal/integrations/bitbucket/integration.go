@@ -0,0 +1,256 @@
+package bitbucket
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/effxhq/vcs-connect/internal/filter"
+	"github.com/effxhq/vcs-connect/internal/integrations"
+	"github.com/effxhq/vcs-connect/internal/logger"
+	"github.com/effxhq/vcs-connect/internal/metrics"
+	"github.com/effxhq/vcs-connect/internal/model"
+	"github.com/effxhq/vcs-connect/internal/tracing"
+
+	"github.com/pkg/errors"
+
+	"go.uber.org/zap"
+)
+
+// NewIntegration returns the Integration responsible for communicating with Bitbucket Cloud.
+// Before construction, the Configuration is validated to ensure it contains the
+// proper information.
+func NewIntegration(ctx context.Context, config *Configuration, filter integrations.Filter) (*Integration, error) {
+	if err := config.Validate(); err != nil {
+		return nil, err
+	}
+
+	return &Integration{
+		client: &http.Client{Transport: metrics.Transport("bitbucket", tracing.Transport(http.DefaultTransport))},
+		config: config,
+		filter: filter,
+	}, nil
+}
+
+// Integration encapsulates the logic for integrating data from Bitbucket Cloud.
+type Integration struct {
+	client *http.Client
+	config *Configuration
+	filter integrations.Filter
+}
+
+type link struct {
+	Name string `json:"name"`
+	Href string `json:"href"`
+}
+
+type workspace struct {
+	Slug string `json:"slug"`
+}
+
+// ReportedProperties are the properties Bitbucket Cloud reports for repositories.
+const ReportedProperties = filter.Forks | filter.Visibility
+
+type repository struct {
+	FullName   string      `json:"full_name"`
+	IsPrivate  bool        `json:"is_private"`
+	UpdatedOn  time.Time   `json:"updated_on"`
+	Parent     *repository `json:"parent"`
+	MainBranch *struct {
+		Name string `json:"name"`
+	} `json:"mainbranch"`
+	Links struct {
+		Clone []link `json:"clone"`
+		HTML  link   `json:"html"`
+	} `json:"links"`
+}
+
+func (r *repository) defaultBranch() string {
+	if r.MainBranch == nil {
+		return ""
+	}
+	return r.MainBranch.Name
+}
+
+func (r *repository) visibility() string {
+	if r.IsPrivate {
+		return "private"
+	}
+	return "public"
+}
+
+// cloneURL returns the https clone link of the repository without the embedded username.
+func (r *repository) cloneURL() string {
+	href := r.link("https")
+
+	u, err := url.Parse(href)
+	if err != nil {
+		return href
+	}
+	u.User = nil
+	return u.String()
+}
+
+// link returns the clone link of the repository for the named protocol.
+func (r *repository) link(name string) string {
+	for _, clone := range r.Links.Clone {
+		if clone.Name == name {
+			return clone.Href
+		}
+	}
+	return ""
+}
+
+// get performs an authenticated request against the API and decodes the paged response.
+// It returns the url of the next page, or an empty string when there are no more pages.
+func (i *Integration) get(ctx context.Context, endpoint string, values interface{}) (string, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, endpoint, nil)
+	if err != nil {
+		return "", err
+	}
+	req.SetBasicAuth(i.config.UserName, i.config.AppPassword)
+	req.Header.Add("accept", "application/json")
+
+	resp, err := i.client.Do(req)
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("unexpected status code %d from %s", resp.StatusCode, endpoint)
+	}
+
+	page := struct {
+		Values interface{} `json:"values"`
+		Next   string      `json:"next"`
+	}{
+		Values: values,
+	}
+
+	if err := json.NewDecoder(resp.Body).Decode(&page); err != nil {
+		return "", err
+	}
+
+	return page.Next, nil
+}
+
+func (i *Integration) discoverWorkspaces(ctx context.Context) ([]string, error) {
+	configured := i.config.Workspaces.Value()
+	if len(configured) > 0 {
+		return configured, nil
+	}
+
+	workspaces := make([]string, 0)
+	next := strings.TrimSuffix(i.config.BaseURL, "/") + "/workspaces?pagelen=100"
+
+	for next != "" {
+		wss := make([]*workspace, 0)
+
+		var err error
+		next, err = i.get(ctx, next, &wss)
+		if err != nil {
+			return nil, err
+		}
+
+		results := make([]string, len(wss))
+		for i, ws := range wss {
+			results[i] = ws.Slug
+		}
+
+		workspaces = append(workspaces, results...)
+	}
+
+	return workspaces, nil
+}
+
+func (i *Integration) discoverRepositories(ctx context.Context, workspace string) ([]*model.Repository, error) {
+	repositories := make([]*model.Repository, 0)
+	next := fmt.Sprintf("%s/repositories/%s?pagelen=100",
+		strings.TrimSuffix(i.config.BaseURL, "/"), url.PathEscape(workspace))
+
+	for next != "" {
+		repos := make([]*repository, 0)
+
+		var err error
+		next, err = i.get(ctx, next, &repos)
+		if err != nil {
+			return nil, err
+		}
+
+		results := make([]*model.Repository, 0, len(repos))
+		for _, repo := range repos {
+			cloneURL := repo.cloneURL()
+			if cloneURL == "" {
+				continue
+			}
+
+			results = append(results, &model.Repository{
+				CloneURL:      cloneURL,
+				SSHURL:        repo.link("ssh"),
+				Tags:          map[string]string{},
+				Annotations:   map[string]string{},
+				FullName:      repo.FullName,
+				Owner:         workspace,
+				DefaultBranch: repo.defaultBranch(),
+				WebURL:        repo.Links.HTML.Href,
+				Visibility:    repo.visibility(),
+				Fork:          repo.Parent != nil,
+				PushedAt:      repo.UpdatedOn,
+			})
+		}
+
+		repositories = append(repositories, results...)
+	}
+
+	return repositories, nil
+}
+
+// Run feeds the data channel with results it discovers from Bitbucket Cloud
+func (i *Integration) Run(ctx context.Context, data chan *model.Repository) error {
+	log := logger.MustGetFromContext(ctx)
+
+	workspaces, err := i.discoverWorkspaces(ctx)
+	if err != nil {
+		return errors.Wrap(err, "failed to discover workspaces from Bitbucket")
+	}
+
+	for _, workspace := range workspaces {
+		log.Info("discovering repositories",
+			zap.String("workspace", workspace))
+
+		repositories, err := i.discoverRepositories(ctx, workspace)
+		if err != nil {
+			log.Error("failed to discover repositories",
+				zap.String("workspace", workspace),
+				zap.Error(err))
+			continue
+		}
+
+		// push to consumers or stop if cancelled
+		for _, repository := range repositories {
+			if reason := i.filter.Reason(repository); reason != "" {
+				log.Info("skipping repository",
+					zap.String("repository", repository.CloneURL),
+					zap.String("reason", reason))
+				continue
+			}
+
+			log.Info("processing repository",
+				zap.String("repository", repository.CloneURL))
+
+			select {
+			case <-ctx.Done():
+				return nil
+			case data <- repository:
+				continue
+			}
+		}
+	}
+
+	return nil
+}
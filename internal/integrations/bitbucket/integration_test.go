@@ -0,0 +1,177 @@
+package bitbucket_test
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+
+	"github.com/effxhq/vcs-connect/internal/filter"
+	"github.com/effxhq/vcs-connect/internal/integrations/bitbucket"
+	"github.com/effxhq/vcs-connect/internal/logger"
+	"github.com/effxhq/vcs-connect/internal/model"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/urfave/cli/v2"
+
+	"go.uber.org/zap"
+)
+
+// fakeBitbucket serves pages of the Bitbucket Cloud api, remembering the
+// credentials of each request so the test can check them.
+type fakeBitbucket struct {
+	*http.ServeMux
+
+	mu          sync.Mutex
+	credentials []string
+}
+
+func (f *fakeBitbucket) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	username, password, _ := r.BasicAuth()
+
+	f.mu.Lock()
+	f.credentials = append(f.credentials, username+":"+password)
+	f.mu.Unlock()
+
+	f.ServeMux.ServeHTTP(w, r)
+}
+
+func newFilter(t *testing.T) *filter.Filter {
+	cfg, _ := filter.DefaultConfigWithFlags()
+	repoFilter, err := filter.New(cfg)
+	require.NoError(t, err)
+	return repoFilter
+}
+
+func discover(t *testing.T, api *fakeBitbucket, workspaces ...string) ([]*model.Repository, error) {
+	server := httptest.NewServer(api)
+	defer server.Close()
+
+	ctx := logger.AttachToContext(context.Background(), zap.NewNop())
+
+	integration, err := bitbucket.NewIntegration(ctx, &bitbucket.Configuration{
+		BaseURL:     server.URL + "/2.0/",
+		UserName:    "effx",
+		AppPassword: "app_password",
+		Workspaces:  cli.NewStringSlice(workspaces...),
+	}, newFilter(t))
+	require.NoError(t, err)
+
+	data := make(chan *model.Repository, 10)
+	err = integration.Run(ctx, data)
+	close(data)
+
+	repositories := make([]*model.Repository, 0)
+	for repository := range data {
+		repositories = append(repositories, repository)
+	}
+	return repositories, err
+}
+
+func TestIntegration_Run(t *testing.T) {
+	api := &fakeBitbucket{ServeMux: http.NewServeMux()}
+
+	// workspaces and repositories are both paged through next links
+	api.HandleFunc("/2.0/workspaces", func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Query().Get("page") == "2" {
+			fmt.Fprint(w, `{"values": [{"slug": "platform"}]}`)
+			return
+		}
+		fmt.Fprintf(w, `{"values": [{"slug": "effxhq"}], "next": "http://%s/2.0/workspaces?page=2"}`, r.Host)
+	})
+	api.HandleFunc("/2.0/repositories/effxhq", func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Query().Get("page") == "2" {
+			fmt.Fprint(w, `{"values": [
+				{"links": {"clone": [{"name": "https", "href": "https://bitbucket.org/effxhq/web.git"}]}}
+			]}`)
+			return
+		}
+		fmt.Fprintf(w, `{"values": [
+			{
+				"full_name": "effxhq/api",
+				"is_private": true,
+				"parent": {"full_name": "upstream/api"},
+				"mainbranch": {"name": "main"},
+				"links": {
+					"html": {"href": "https://bitbucket.org/effxhq/api"},
+					"clone": [
+						{"name": "ssh", "href": "git@bitbucket.org:effxhq/api.git"},
+						{"name": "https", "href": "https://effx@bitbucket.org/effxhq/api.git"}
+					]
+				}
+			},
+			{"links": {"clone": [{"name": "ssh", "href": "git@bitbucket.org:effxhq/ssh-only.git"}]}}
+		], "next": "http://%s/2.0/repositories/effxhq?page=2"}`, r.Host)
+	})
+	api.HandleFunc("/2.0/repositories/platform", func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, `{"values": [
+			{"links": {"clone": [{"name": "https", "href": "https://bitbucket.org/platform/tools.git"}]}}
+		]}`)
+	})
+
+	repositories, err := discover(t, api)
+	require.NoError(t, err)
+
+	// the username bitbucket embeds in clone links is dropped, and repositories
+	// without an https clone link are skipped
+	cloneURLs := make([]string, len(repositories))
+	for i, repository := range repositories {
+		cloneURLs[i] = repository.CloneURL
+	}
+	require.Equal(t, []string{
+		"https://bitbucket.org/effxhq/api.git",
+		"https://bitbucket.org/effxhq/web.git",
+		"https://bitbucket.org/platform/tools.git",
+	}, cloneURLs)
+
+	require.Equal(t, &model.Repository{
+		CloneURL:      "https://bitbucket.org/effxhq/api.git",
+		SSHURL:        "git@bitbucket.org:effxhq/api.git",
+		Tags:          map[string]string{},
+		Annotations:   map[string]string{},
+		FullName:      "effxhq/api",
+		Owner:         "effxhq",
+		DefaultBranch: "main",
+		WebURL:        "https://bitbucket.org/effxhq/api",
+		Visibility:    "private",
+		Fork:          true,
+	}, repositories[0])
+
+	// every page is requested with the app password
+	require.Len(t, api.credentials, 5)
+	for _, credentials := range api.credentials {
+		require.Equal(t, "effx:app_password", credentials)
+	}
+}
+
+func TestIntegration_RunConfiguredWorkspaces(t *testing.T) {
+	api := &fakeBitbucket{ServeMux: http.NewServeMux()}
+	api.HandleFunc("/2.0/repositories/broken", func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusInternalServerError)
+	})
+	api.HandleFunc("/2.0/repositories/effxhq", func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, `{"values": [
+			{"links": {"clone": [{"name": "https", "href": "https://bitbucket.org/effxhq/api.git"}]}}
+		]}`)
+	})
+
+	// configured workspaces are not listed, and failing ones do not stop the others
+	repositories, err := discover(t, api, "broken", "effxhq")
+	require.NoError(t, err)
+	require.Len(t, repositories, 1)
+	require.Equal(t, "https://bitbucket.org/effxhq/api.git", repositories[0].CloneURL)
+}
+
+func TestIntegration_RunUnauthorized(t *testing.T) {
+	api := &fakeBitbucket{ServeMux: http.NewServeMux()}
+	api.HandleFunc("/2.0/workspaces", func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusUnauthorized)
+	})
+
+	_, err := discover(t, api)
+	require.Error(t, err)
+	require.Contains(t, err.Error(), "unexpected status code 401")
+}
@@ -0,0 +1,68 @@
+package bitbucketserver
+
+import (
+	"fmt"
+
+	"github.com/urfave/cli/v2"
+)
+
+// Configuration encapsulates information needed for communicating with a
+// Bitbucket Server / Data Center instance
+type Configuration struct {
+	BaseURL     string
+	UserName    string
+	AccessToken string
+	Projects    *cli.StringSlice
+}
+
+// Validate ensures the configuration provided contains the required information.
+func (c *Configuration) Validate() error {
+	if c.BaseURL == "" {
+		return fmt.Errorf("a base url must be provided")
+	} else if c.UserName == "" {
+		return fmt.Errorf("a username must be provided")
+	} else if c.AccessToken == "" {
+		return fmt.Errorf("an access token must be provided")
+	}
+	return nil
+}
+
+// DefaultConfigWithFlags returns configuration and flags specific to Bitbucket Server
+func DefaultConfigWithFlags() (*Configuration, []cli.Flag) {
+	cfg := &Configuration{
+		Projects: cli.NewStringSlice(),
+	}
+
+	flags := []cli.Flag{
+		&cli.StringFlag{
+			Name:        "bitbucket-server-base-url",
+			Usage:       "url to the Bitbucket Server instance",
+			Destination: &(cfg.BaseURL),
+			Value:       cfg.BaseURL,
+			EnvVars:     []string{"BITBUCKET_SERVER_BASE_URL"},
+		},
+		&cli.StringFlag{
+			Name:        "bitbucket-server-username",
+			Usage:       "the user associated with the http access token",
+			Destination: &(cfg.UserName),
+			Value:       cfg.UserName,
+			EnvVars:     []string{"BITBUCKET_SERVER_USERNAME"},
+		},
+		&cli.StringFlag{
+			Name:        "bitbucket-server-access-token",
+			Usage:       "used to read data from the Bitbucket Server API and clone repositories",
+			Destination: &(cfg.AccessToken),
+			Value:       cfg.AccessToken,
+			EnvVars:     []string{"BITBUCKET_SERVER_ACCESS_TOKEN"},
+		},
+		&cli.StringSliceFlag{
+			Name:        "bitbucket-server-projects",
+			Usage:       "restricts operations to listed Bitbucket Server project keys",
+			Destination: cfg.Projects,
+			Value:       cfg.Projects,
+			EnvVars:     []string{"BITBUCKET_SERVER_PROJECTS"},
+		},
+	}
+
+	return cfg, flags
+}
@@ -0,0 +1,259 @@
+package bitbucketserver
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"github.com/effxhq/vcs-connect/internal/filter"
+	"github.com/effxhq/vcs-connect/internal/integrations"
+	"github.com/effxhq/vcs-connect/internal/logger"
+	"github.com/effxhq/vcs-connect/internal/metrics"
+	"github.com/effxhq/vcs-connect/internal/model"
+	"github.com/effxhq/vcs-connect/internal/tracing"
+
+	"github.com/pkg/errors"
+
+	"go.uber.org/zap"
+)
+
+// NewIntegration returns the Integration responsible for communicating with Bitbucket Server.
+// Before construction, the Configuration is validated to ensure it contains the
+// proper information.
+func NewIntegration(ctx context.Context, config *Configuration, filter integrations.Filter) (*Integration, error) {
+	if err := config.Validate(); err != nil {
+		return nil, err
+	}
+
+	return &Integration{
+		client: &http.Client{Transport: metrics.Transport("bitbucket-server", tracing.Transport(http.DefaultTransport))},
+		config: config,
+		filter: filter,
+	}, nil
+}
+
+// Integration encapsulates the logic for integrating data from Bitbucket Server.
+type Integration struct {
+	client *http.Client
+	config *Configuration
+	filter integrations.Filter
+}
+
+type link struct {
+	Name string `json:"name"`
+	Href string `json:"href"`
+}
+
+type project struct {
+	Key string `json:"key"`
+}
+
+// ReportedProperties are the properties Bitbucket Server reports for repositories.
+const ReportedProperties = filter.Archived | filter.Forks | filter.Visibility
+
+type repository struct {
+	Slug     string      `json:"slug"`
+	Public   bool        `json:"public"`
+	Archived bool        `json:"archived"`
+	Origin   *repository `json:"origin"`
+	Project  project     `json:"project"`
+	Links    struct {
+		Clone []link `json:"clone"`
+		Self  []link `json:"self"`
+	} `json:"links"`
+}
+
+func (r *repository) webURL() string {
+	if len(r.Links.Self) == 0 {
+		return ""
+	}
+	return r.Links.Self[0].Href
+}
+
+func (r *repository) visibility() string {
+	if r.Public {
+		return "public"
+	}
+	return "private"
+}
+
+// cloneURL returns the http clone link of the repository without the embedded username.
+func (r *repository) cloneURL() string {
+	href := r.link("http")
+
+	u, err := url.Parse(href)
+	if err != nil {
+		return href
+	}
+	u.User = nil
+	return u.String()
+}
+
+// link returns the clone link of the repository for the named protocol.
+func (r *repository) link(name string) string {
+	for _, clone := range r.Links.Clone {
+		if clone.Name == name {
+			return clone.Href
+		}
+	}
+	return ""
+}
+
+// get performs an authenticated request against the REST API and decodes the paged response.
+// It returns the start of the next page, or -1 when the last page has been read.
+func (i *Integration) get(ctx context.Context, path string, start int, values interface{}) (int, error) {
+	endpoint := fmt.Sprintf("%s/rest/api/1.0%s?start=%d&limit=100",
+		strings.TrimSuffix(i.config.BaseURL, "/"), path, start)
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, endpoint, nil)
+	if err != nil {
+		return -1, err
+	}
+	req.Header.Add("authorization", "Bearer "+i.config.AccessToken)
+	req.Header.Add("accept", "application/json")
+
+	resp, err := i.client.Do(req)
+	if err != nil {
+		return -1, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return -1, fmt.Errorf("unexpected status code %d from %s", resp.StatusCode, endpoint)
+	}
+
+	page := struct {
+		Values        interface{} `json:"values"`
+		IsLastPage    bool        `json:"isLastPage"`
+		NextPageStart int         `json:"nextPageStart"`
+	}{
+		Values: values,
+	}
+
+	if err := json.NewDecoder(resp.Body).Decode(&page); err != nil {
+		return -1, err
+	}
+
+	if page.IsLastPage {
+		return -1, nil
+	}
+	return page.NextPageStart, nil
+}
+
+func (i *Integration) discoverProjects(ctx context.Context) ([]string, error) {
+	configured := i.config.Projects.Value()
+	if len(configured) > 0 {
+		return configured, nil
+	}
+
+	projects := make([]string, 0)
+	start := 0
+
+	for start >= 0 {
+		prjs := make([]*project, 0)
+
+		var err error
+		start, err = i.get(ctx, "/projects", start, &prjs)
+		if err != nil {
+			return nil, err
+		}
+
+		results := make([]string, len(prjs))
+		for i, prj := range prjs {
+			results[i] = prj.Key
+		}
+
+		projects = append(projects, results...)
+	}
+
+	return projects, nil
+}
+
+func (i *Integration) discoverRepositories(ctx context.Context, project string) ([]*model.Repository, error) {
+	repositories := make([]*model.Repository, 0)
+	path := fmt.Sprintf("/projects/%s/repos", url.PathEscape(project))
+
+	start := 0
+	for start >= 0 {
+		repos := make([]*repository, 0)
+
+		var err error
+		start, err = i.get(ctx, path, start, &repos)
+		if err != nil {
+			return nil, err
+		}
+
+		results := make([]*model.Repository, 0, len(repos))
+		for _, repo := range repos {
+			cloneURL := repo.cloneURL()
+			if cloneURL == "" {
+				continue
+			}
+
+			results = append(results, &model.Repository{
+				CloneURL:    cloneURL,
+				SSHURL:      repo.link("ssh"),
+				Tags:        map[string]string{},
+				Annotations: map[string]string{},
+				FullName:    repo.Project.Key + "/" + repo.Slug,
+				Owner:       repo.Project.Key,
+				WebURL:      repo.webURL(),
+				Visibility:  repo.visibility(),
+				Archived:    repo.Archived,
+				Fork:        repo.Origin != nil,
+			})
+		}
+
+		repositories = append(repositories, results...)
+	}
+
+	return repositories, nil
+}
+
+// Run feeds the data channel with results it discovers from Bitbucket Server
+func (i *Integration) Run(ctx context.Context, data chan *model.Repository) error {
+	log := logger.MustGetFromContext(ctx)
+
+	projects, err := i.discoverProjects(ctx)
+	if err != nil {
+		return errors.Wrap(err, "failed to discover projects from Bitbucket Server")
+	}
+
+	for _, project := range projects {
+		log.Info("discovering repositories",
+			zap.String("project", project))
+
+		repositories, err := i.discoverRepositories(ctx, project)
+		if err != nil {
+			log.Error("failed to discover repositories",
+				zap.String("project", project),
+				zap.Error(err))
+			continue
+		}
+
+		// push to consumers or stop if cancelled
+		for _, repository := range repositories {
+			if reason := i.filter.Reason(repository); reason != "" {
+				log.Info("skipping repository",
+					zap.String("repository", repository.CloneURL),
+					zap.String("reason", reason))
+				continue
+			}
+
+			log.Info("processing repository",
+				zap.String("repository", repository.CloneURL))
+
+			select {
+			case <-ctx.Done():
+				return nil
+			case data <- repository:
+				continue
+			}
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,176 @@
+package bitbucketserver_test
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+
+	"github.com/effxhq/vcs-connect/internal/filter"
+	"github.com/effxhq/vcs-connect/internal/integrations/bitbucketserver"
+	"github.com/effxhq/vcs-connect/internal/logger"
+	"github.com/effxhq/vcs-connect/internal/model"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/urfave/cli/v2"
+
+	"go.uber.org/zap"
+)
+
+// pages maps the start of each page of an endpoint to its response.
+type pages map[string]string
+
+// newServer serves the paged endpoints of the Bitbucket Server REST API. Every
+// request is recorded as its path, start and authorization header.
+func newServer(endpoints map[string]pages) (*httptest.Server, func() []string) {
+	var (
+		mu       sync.Mutex
+		requests []string
+	)
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		start := r.URL.Query().Get("start")
+
+		mu.Lock()
+		requests = append(requests, fmt.Sprintf("%s?start=%s %s", r.URL.Path, start, r.Header.Get("authorization")))
+		mu.Unlock()
+
+		body, ok := endpoints[r.URL.Path][start]
+		if !ok {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		fmt.Fprint(w, body)
+	}))
+
+	return server, func() []string {
+		mu.Lock()
+		defer mu.Unlock()
+		return append([]string{}, requests...)
+	}
+}
+
+func newFilter(t *testing.T) *filter.Filter {
+	cfg, _ := filter.DefaultConfigWithFlags()
+	repoFilter, err := filter.New(cfg)
+	require.NoError(t, err)
+	return repoFilter
+}
+
+func discover(t *testing.T, baseURL string, projects ...string) ([]*model.Repository, error) {
+	ctx := logger.AttachToContext(context.Background(), zap.NewNop())
+
+	integration, err := bitbucketserver.NewIntegration(ctx, &bitbucketserver.Configuration{
+		BaseURL:     baseURL,
+		UserName:    "effx",
+		AccessToken: "access_token",
+		Projects:    cli.NewStringSlice(projects...),
+	}, newFilter(t))
+	require.NoError(t, err)
+
+	data := make(chan *model.Repository, 10)
+	err = integration.Run(ctx, data)
+	close(data)
+
+	repositories := make([]*model.Repository, 0)
+	for repository := range data {
+		repositories = append(repositories, repository)
+	}
+	return repositories, err
+}
+
+func TestIntegration_Run(t *testing.T) {
+	server, requests := newServer(map[string]pages{
+		"/rest/api/1.0/projects": {
+			"0": `{"values": [{"key": "EFFX"}, {"key": "OPS"}], "isLastPage": true}`,
+		},
+		"/rest/api/1.0/projects/EFFX/repos": {
+			"0": `{"values": [
+				{
+					"slug": "api",
+					"project": {"key": "EFFX"},
+					"archived": true,
+					"origin": {"slug": "api"},
+					"links": {
+						"self": [{"href": "https://bitbucket.effx.io/projects/EFFX/repos/api/browse"}],
+						"clone": [
+							{"name": "ssh", "href": "ssh://git@bitbucket.effx.io:7999/effx/api.git"},
+							{"name": "http", "href": "https://effx@bitbucket.effx.io/scm/effx/api.git"}
+						]
+					}
+				}
+			], "isLastPage": false, "nextPageStart": 25}`,
+			"25": `{"values": [
+				{"links": {"clone": [{"name": "ssh", "href": "ssh://git@bitbucket.effx.io:7999/effx/ssh-only.git"}]}},
+				{"slug": "web", "project": {"key": "EFFX"}, "public": true, "links": {"clone": [{"name": "http", "href": "https://bitbucket.effx.io/scm/effx/web.git"}]}}
+			], "isLastPage": true}`,
+		},
+		"/rest/api/1.0/projects/OPS/repos": {
+			"0": `{"values": [], "isLastPage": true}`,
+		},
+	})
+	defer server.Close()
+
+	repositories, err := discover(t, server.URL)
+	require.NoError(t, err)
+
+	// the username embedded in http clone links is dropped, and repositories
+	// that can only be cloned over ssh are skipped
+	require.Equal(t, []*model.Repository{
+		{
+			CloneURL:    "https://bitbucket.effx.io/scm/effx/api.git",
+			SSHURL:      "ssh://git@bitbucket.effx.io:7999/effx/api.git",
+			Tags:        map[string]string{},
+			Annotations: map[string]string{},
+			FullName:    "EFFX/api",
+			Owner:       "EFFX",
+			WebURL:      "https://bitbucket.effx.io/projects/EFFX/repos/api/browse",
+			Visibility:  "private",
+			Archived:    true,
+			Fork:        true,
+		},
+		{
+			CloneURL:    "https://bitbucket.effx.io/scm/effx/web.git",
+			Tags:        map[string]string{},
+			Annotations: map[string]string{},
+			FullName:    "EFFX/web",
+			Owner:       "EFFX",
+			Visibility:  "public",
+		},
+	}, repositories)
+
+	// pages are requested from where the previous one ended, with the access token
+	require.Equal(t, []string{
+		"/rest/api/1.0/projects?start=0 Bearer access_token",
+		"/rest/api/1.0/projects/EFFX/repos?start=0 Bearer access_token",
+		"/rest/api/1.0/projects/EFFX/repos?start=25 Bearer access_token",
+		"/rest/api/1.0/projects/OPS/repos?start=0 Bearer access_token",
+	}, requests())
+}
+
+func TestIntegration_RunErrors(t *testing.T) {
+	server, _ := newServer(map[string]pages{
+		"/rest/api/1.0/projects/EFFX/repos": {
+			"0": `{"values": [
+				{"links": {"clone": [{"name": "http", "href": "https://bitbucket.effx.io/scm/effx/api.git"}]}}
+			], "isLastPage": true}`,
+		},
+		"/rest/api/1.0/projects/BROKEN/repos": {
+			"0": `not json`,
+		},
+	})
+	defer server.Close()
+
+	// projects failing to list are skipped
+	repositories, err := discover(t, server.URL, "BROKEN", "MISSING", "EFFX")
+	require.NoError(t, err)
+	require.Len(t, repositories, 1)
+	require.Equal(t, "https://bitbucket.effx.io/scm/effx/api.git", repositories[0].CloneURL)
+
+	// failing to list projects fails the run
+	_, err = discover(t, server.URL)
+	require.Error(t, err)
+}
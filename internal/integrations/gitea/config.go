@@ -0,0 +1,68 @@
+package gitea
+
+import (
+	"fmt"
+
+	"github.com/urfave/cli/v2"
+)
+
+// Configuration encapsulates information needed for communicating with a
+// Gitea (or Forgejo) API instance
+type Configuration struct {
+	BaseURL       string
+	UserName      string
+	AccessToken   string
+	Organizations *cli.StringSlice
+}
+
+// Validate ensures the configuration provided contains the required information.
+func (c *Configuration) Validate() error {
+	if c.BaseURL == "" {
+		return fmt.Errorf("a base url must be provided")
+	} else if c.UserName == "" {
+		return fmt.Errorf("a username must be provided")
+	} else if c.AccessToken == "" {
+		return fmt.Errorf("an access token must be provided")
+	}
+	return nil
+}
+
+// DefaultConfigWithFlags returns configuration and flags specific to Gitea
+func DefaultConfigWithFlags() (*Configuration, []cli.Flag) {
+	cfg := &Configuration{
+		Organizations: cli.NewStringSlice(),
+	}
+
+	flags := []cli.Flag{
+		&cli.StringFlag{
+			Name:        "gitea-base-url",
+			Usage:       "url to the Gitea instance",
+			Destination: &(cfg.BaseURL),
+			Value:       cfg.BaseURL,
+			EnvVars:     []string{"GITEA_BASE_URL"},
+		},
+		&cli.StringFlag{
+			Name:        "gitea-username",
+			Usage:       "the user associated with the access token",
+			Destination: &(cfg.UserName),
+			Value:       cfg.UserName,
+			EnvVars:     []string{"GITEA_USERNAME"},
+		},
+		&cli.StringFlag{
+			Name:        "gitea-access-token",
+			Usage:       "used to read data from the Gitea API and clone repositories",
+			Destination: &(cfg.AccessToken),
+			Value:       cfg.AccessToken,
+			EnvVars:     []string{"GITEA_ACCESS_TOKEN"},
+		},
+		&cli.StringSliceFlag{
+			Name:        "gitea-organizations",
+			Usage:       "restricts operations to listed Gitea organizations",
+			Destination: cfg.Organizations,
+			Value:       cfg.Organizations,
+			EnvVars:     []string{"GITEA_ORGANIZATIONS"},
+		},
+	}
+
+	return cfg, flags
+}
@@ -0,0 +1,222 @@
+package gitea
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/effxhq/vcs-connect/internal/filter"
+	"github.com/effxhq/vcs-connect/internal/integrations"
+	"github.com/effxhq/vcs-connect/internal/logger"
+	"github.com/effxhq/vcs-connect/internal/metrics"
+	"github.com/effxhq/vcs-connect/internal/model"
+	"github.com/effxhq/vcs-connect/internal/tracing"
+
+	"github.com/pkg/errors"
+
+	"go.uber.org/zap"
+)
+
+const pageSize = 50
+
+// NewIntegration returns the Integration responsible for communicating with Gitea.
+// Before construction, the Configuration is validated to ensure it contains the
+// proper information.
+func NewIntegration(ctx context.Context, config *Configuration, filter integrations.Filter) (*Integration, error) {
+	if err := config.Validate(); err != nil {
+		return nil, err
+	}
+
+	return &Integration{
+		client: &http.Client{Transport: metrics.Transport("gitea", tracing.Transport(http.DefaultTransport))},
+		config: config,
+		filter: filter,
+	}, nil
+}
+
+// Integration encapsulates the logic for integrating data from Gitea.
+type Integration struct {
+	client *http.Client
+	config *Configuration
+	filter integrations.Filter
+}
+
+type organization struct {
+	UserName string `json:"username"`
+}
+
+// ReportedProperties are the properties Gitea reports for repositories.
+const ReportedProperties = filter.Archived | filter.Forks | filter.Templates | filter.Mirrors |
+	filter.Empty | filter.Visibility
+
+type repository struct {
+	CloneURL      string `json:"clone_url"`
+	SSHURL        string `json:"ssh_url"`
+	FullName      string `json:"full_name"`
+	HTMLURL       string `json:"html_url"`
+	DefaultBranch string `json:"default_branch"`
+	Owner         struct {
+		Login string `json:"login"`
+	} `json:"owner"`
+	Private   bool      `json:"private"`
+	Internal  bool      `json:"internal"`
+	Archived  bool      `json:"archived"`
+	Fork      bool      `json:"fork"`
+	Template  bool      `json:"template"`
+	Mirror    bool      `json:"mirror"`
+	Empty     bool      `json:"empty"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+func (r *repository) visibility() string {
+	switch {
+	case r.Private:
+		return "private"
+	case r.Internal:
+		return "internal"
+	default:
+		return "public"
+	}
+}
+
+// get performs an authenticated request against the API and decodes a single page into out.
+func (i *Integration) get(ctx context.Context, path string, page int, out interface{}) error {
+	endpoint := fmt.Sprintf("%s/api/v1%s?page=%d&limit=%d",
+		strings.TrimSuffix(i.config.BaseURL, "/"), path, page, pageSize)
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, endpoint, nil)
+	if err != nil {
+		return err
+	}
+	req.Header.Add("authorization", "token "+i.config.AccessToken)
+	req.Header.Add("accept", "application/json")
+
+	resp, err := i.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("unexpected status code %d from %s", resp.StatusCode, endpoint)
+	}
+
+	return json.NewDecoder(resp.Body).Decode(out)
+}
+
+func (i *Integration) discoverOrganizations(ctx context.Context) ([]string, error) {
+	configured := i.config.Organizations.Value()
+	if len(configured) > 0 {
+		return configured, nil
+	}
+
+	organizations := make([]string, 0)
+
+	// an empty page signals the end of the listing
+	for page := 1; ; page++ {
+		orgs := make([]*organization, 0)
+		if err := i.get(ctx, "/user/orgs", page, &orgs); err != nil {
+			return nil, err
+		} else if len(orgs) == 0 {
+			break
+		}
+
+		results := make([]string, len(orgs))
+		for i, org := range orgs {
+			results[i] = org.UserName
+		}
+
+		organizations = append(organizations, results...)
+	}
+
+	return organizations, nil
+}
+
+func (i *Integration) discoverRepositories(ctx context.Context, organization string) ([]*model.Repository, error) {
+	repositories := make([]*model.Repository, 0)
+	path := fmt.Sprintf("/orgs/%s/repos", url.PathEscape(organization))
+
+	// an empty page signals the end of the listing
+	for page := 1; ; page++ {
+		repos := make([]*repository, 0)
+		if err := i.get(ctx, path, page, &repos); err != nil {
+			return nil, err
+		} else if len(repos) == 0 {
+			break
+		}
+
+		results := make([]*model.Repository, len(repos))
+		for i, repo := range repos {
+			results[i] = &model.Repository{
+				CloneURL:      repo.CloneURL,
+				SSHURL:        repo.SSHURL,
+				Tags:          map[string]string{},
+				Annotations:   map[string]string{},
+				FullName:      repo.FullName,
+				Owner:         repo.Owner.Login,
+				DefaultBranch: repo.DefaultBranch,
+				WebURL:        repo.HTMLURL,
+				Visibility:    repo.visibility(),
+				Archived:      repo.Archived,
+				Fork:          repo.Fork,
+				Template:      repo.Template,
+				Mirror:        repo.Mirror,
+				Empty:         repo.Empty,
+				PushedAt:      repo.UpdatedAt,
+			}
+		}
+
+		repositories = append(repositories, results...)
+	}
+
+	return repositories, nil
+}
+
+// Run feeds the data channel with results it discovers from Gitea
+func (i *Integration) Run(ctx context.Context, data chan *model.Repository) error {
+	log := logger.MustGetFromContext(ctx)
+
+	organizations, err := i.discoverOrganizations(ctx)
+	if err != nil {
+		return errors.Wrap(err, "failed to discover organizations from Gitea")
+	}
+
+	for _, organization := range organizations {
+		log.Info("discovering repositories",
+			zap.String("organization", organization))
+
+		repositories, err := i.discoverRepositories(ctx, organization)
+		if err != nil {
+			log.Error("failed to discover repositories",
+				zap.String("organization", organization),
+				zap.Error(err))
+			continue
+		}
+
+		// push to consumers or stop if cancelled
+		for _, repository := range repositories {
+			if reason := i.filter.Reason(repository); reason != "" {
+				log.Info("skipping repository",
+					zap.String("repository", repository.CloneURL),
+					zap.String("reason", reason))
+				continue
+			}
+
+			log.Info("processing repository",
+				zap.String("repository", repository.CloneURL))
+
+			select {
+			case <-ctx.Done():
+				return nil
+			case data <- repository:
+				continue
+			}
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,207 @@
+package gitea_test
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"sync"
+	"testing"
+
+	"github.com/effxhq/vcs-connect/internal/filter"
+	"github.com/effxhq/vcs-connect/internal/integrations/gitea"
+	"github.com/effxhq/vcs-connect/internal/logger"
+	"github.com/effxhq/vcs-connect/internal/model"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/urfave/cli/v2"
+
+	"go.uber.org/zap"
+)
+
+// listing serves the items of a Gitea listing endpoint in pages of the requested
+// limit, answering with an empty page once every item was listed like Gitea does.
+func listing(items ...interface{}) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		page, _ := strconv.Atoi(r.URL.Query().Get("page"))
+		limit, _ := strconv.Atoi(r.URL.Query().Get("limit"))
+
+		start := (page - 1) * limit
+		if start > len(items) {
+			start = len(items)
+		}
+		end := start + limit
+		if end > len(items) {
+			end = len(items)
+		}
+
+		json.NewEncoder(w).Encode(items[start:end])
+	}
+}
+
+func repositories(count int, owner string) []interface{} {
+	repos := make([]interface{}, count)
+	for i := range repos {
+		name := owner + "/repo-" + strconv.Itoa(i)
+		repos[i] = map[string]interface{}{
+			"full_name":      name,
+			"clone_url":      "https://gitea.effx.io/" + name + ".git",
+			"html_url":       "https://gitea.effx.io/" + name,
+			"default_branch": "main",
+			"owner":          map[string]string{"login": owner},
+		}
+	}
+	return repos
+}
+
+func newFilter(t *testing.T) *filter.Filter {
+	cfg, _ := filter.DefaultConfigWithFlags()
+	repoFilter, err := filter.New(cfg)
+	require.NoError(t, err)
+	return repoFilter
+}
+
+func TestIntegration_Run(t *testing.T) {
+	mux := http.NewServeMux()
+	mux.Handle("/api/v1/user/orgs", listing(
+		map[string]string{"username": "effxhq"},
+		map[string]string{"username": "platform"},
+	))
+	// more repositories than fit on a single page
+	mux.Handle("/api/v1/orgs/effxhq/repos", listing(repositories(60, "effxhq")...))
+	mux.Handle("/api/v1/orgs/platform/repos", listing())
+
+	var (
+		mu     sync.Mutex
+		tokens = make(map[string]bool)
+	)
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		tokens[r.Header.Get("authorization")] = true
+		mu.Unlock()
+
+		mux.ServeHTTP(w, r)
+	}))
+	defer server.Close()
+
+	ctx := logger.AttachToContext(context.Background(), zap.NewNop())
+
+	integration, err := gitea.NewIntegration(ctx, &gitea.Configuration{
+		BaseURL:       server.URL + "/",
+		UserName:      "effx",
+		AccessToken:   "access_token",
+		Organizations: cli.NewStringSlice(),
+	}, newFilter(t))
+	require.NoError(t, err)
+
+	data := make(chan *model.Repository, 100)
+	require.NoError(t, integration.Run(ctx, data))
+	close(data)
+
+	discovered := make([]*model.Repository, 0)
+	for repository := range data {
+		discovered = append(discovered, repository)
+	}
+
+	require.Len(t, discovered, 60)
+	require.Equal(t, &model.Repository{
+		CloneURL:      "https://gitea.effx.io/effxhq/repo-0.git",
+		Tags:          map[string]string{},
+		Annotations:   map[string]string{},
+		FullName:      "effxhq/repo-0",
+		Owner:         "effxhq",
+		DefaultBranch: "main",
+		WebURL:        "https://gitea.effx.io/effxhq/repo-0",
+		Visibility:    "public",
+	}, discovered[0])
+	require.Equal(t, "https://gitea.effx.io/effxhq/repo-59.git", discovered[59].CloneURL)
+
+	// every request authenticates with the access token
+	require.Equal(t, map[string]bool{"token access_token": true}, tokens)
+}
+
+func TestIntegration_RunErrors(t *testing.T) {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/api/v1/user/orgs", func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusForbidden)
+	})
+	mux.Handle("/api/v1/orgs/effxhq/repos", listing(repositories(1, "effxhq")...))
+
+	server := httptest.NewServer(mux)
+	defer server.Close()
+
+	ctx := logger.AttachToContext(context.Background(), zap.NewNop())
+
+	run := func(organizations ...string) ([]*model.Repository, error) {
+		integration, err := gitea.NewIntegration(ctx, &gitea.Configuration{
+			BaseURL:       server.URL,
+			UserName:      "effx",
+			AccessToken:   "access_token",
+			Organizations: cli.NewStringSlice(organizations...),
+		}, newFilter(t))
+		require.NoError(t, err)
+
+		data := make(chan *model.Repository, 10)
+		err = integration.Run(ctx, data)
+		close(data)
+
+		discovered := make([]*model.Repository, 0)
+		for repository := range data {
+			discovered = append(discovered, repository)
+		}
+		return discovered, err
+	}
+
+	// organizations cannot be listed
+	_, err := run()
+	require.Error(t, err)
+
+	// configured organizations that fail to list are skipped
+	discovered, err := run("missing", "effxhq")
+	require.NoError(t, err)
+	require.Len(t, discovered, 1)
+}
+
+func TestIntegration_RunFilters(t *testing.T) {
+	mux := http.NewServeMux()
+	mux.Handle("/api/v1/orgs/effxhq/repos", listing(
+		map[string]interface{}{"full_name": "effxhq/api", "clone_url": "https://gitea.effx.io/effxhq/api.git"},
+		map[string]interface{}{"full_name": "effxhq/template", "clone_url": "https://gitea.effx.io/effxhq/template.git", "template": true},
+		map[string]interface{}{"full_name": "effxhq/mirror", "clone_url": "https://gitea.effx.io/effxhq/mirror.git", "mirror": true},
+	))
+
+	server := httptest.NewServer(mux)
+	defer server.Close()
+
+	// templates and mirrors are reported by Gitea, so they can be excluded
+	cfg, _ := filter.DefaultConfigWithFlags()
+	cfg.ExcludeTemplates = true
+	cfg.ExcludeMirrors = true
+	require.NoError(t, cfg.Supported("Gitea", gitea.ReportedProperties))
+
+	repoFilter, err := filter.New(cfg)
+	require.NoError(t, err)
+
+	ctx := logger.AttachToContext(context.Background(), zap.NewNop())
+
+	integration, err := gitea.NewIntegration(ctx, &gitea.Configuration{
+		BaseURL:       server.URL,
+		UserName:      "effx",
+		AccessToken:   "access_token",
+		Organizations: cli.NewStringSlice("effxhq"),
+	}, repoFilter)
+	require.NoError(t, err)
+
+	data := make(chan *model.Repository, 10)
+	require.NoError(t, integration.Run(ctx, data))
+	close(data)
+
+	fullNames := make([]string, 0)
+	for repository := range data {
+		fullNames = append(fullNames, repository.FullName)
+	}
+	require.Equal(t, []string{"effxhq/api"}, fullNames)
+}
@@ -0,0 +1,144 @@
+package github
+
+import (
+	"context"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"strings"
+	"sync"
+
+	"github.com/bradleyfalzon/ghinstallation"
+
+	"github.com/google/go-github/v29/github"
+
+	"github.com/pkg/errors"
+)
+
+// InstallationAuth authenticates git operations using GitHub App installation tokens.
+// Tokens are short-lived, so a fresh one is minted whenever the current token nears
+// expiration.
+type InstallationAuth struct {
+	transport *ghinstallation.Transport
+
+	mu  sync.Mutex
+	err error
+}
+
+// Name returns the name of the authentication method.
+func (a *InstallationAuth) Name() string {
+	return "http-github-app"
+}
+
+// String returns a description of the authentication method without the token.
+func (a *InstallationAuth) String() string {
+	return fmt.Sprintf("%s - x-access-token:<installation token>", a.Name())
+}
+
+// Refresh mints a new installation token when the current one nears expiration.
+// It is called before cloning, so failures to mint a token are reported instead
+// of being hidden behind the remote rejecting an unauthenticated clone.
+func (a *InstallationAuth) Refresh(ctx context.Context) error {
+	_, err := a.transport.Token(ctx)
+	err = errors.Wrap(err, "failed to mint installation token")
+
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	a.err = err
+	return err
+}
+
+// Err returns the last failure to mint an installation token, until a token is
+// minted successfully by Refresh.
+func (a *InstallationAuth) Err() error {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	return a.err
+}
+
+// SetAuth attaches the current installation token to the request.
+func (a *InstallationAuth) SetAuth(r *http.Request) {
+	token, err := a.transport.Token(r.Context())
+	if err != nil {
+		// Refresh reports the error before cloning, so this only happens if the
+		// token expired mid-clone and minting a new one failed. The request can
+		// only be sent without credentials, so the failure is kept for Err to
+		// fail the clone with.
+		a.mu.Lock()
+		a.err = errors.Wrap(err, "failed to mint installation token")
+		a.mu.Unlock()
+		return
+	}
+	r.SetBasicAuth("x-access-token", token)
+}
+
+func newAppsTransport(config *Configuration) (*ghinstallation.AppsTransport, error) {
+	privateKey := []byte(config.AppPrivateKey)
+	if config.AppPrivateKeyFile != "" {
+		var err error
+		privateKey, err = ioutil.ReadFile(config.AppPrivateKeyFile)
+		if err != nil {
+			return nil, errors.Wrap(err, "failed to read private key")
+		}
+	}
+
+	appsTransport, err := ghinstallation.NewAppsTransport(http.DefaultTransport, config.AppID, privateKey)
+	if err != nil {
+		return nil, err
+	}
+
+	if config.BaseURL != "" {
+		appsTransport.BaseURL = strings.TrimSuffix(config.BaseURL, "/")
+	}
+
+	return appsTransport, nil
+}
+
+// discoverInstallation locates the installation of the GitHub App to use. When
+// organizations are configured, the installation must cover all of them. Otherwise,
+// the app must be installed exactly once.
+func discoverInstallation(ctx context.Context, client *github.Client, config *Configuration) (*github.Installation, error) {
+	if config.AppInstallationID != 0 {
+		installation, _, err := client.Apps.GetInstallation(ctx, config.AppInstallationID)
+		return installation, err
+	}
+
+	organizations := config.Organizations.Value()
+	if len(organizations) > 0 {
+		var installation *github.Installation
+
+		for _, organization := range organizations {
+			inst, _, err := client.Apps.FindOrganizationInstallation(ctx, organization)
+			if err != nil {
+				return nil, errors.Wrapf(err, "failed to find installation for %s", organization)
+			} else if installation != nil && installation.GetID() != inst.GetID() {
+				return nil, fmt.Errorf("organizations span multiple installations, an installation id must be provided")
+			}
+			installation = inst
+		}
+
+		return installation, nil
+	}
+
+	installations := make([]*github.Installation, 0)
+	page := 1
+
+	for page > 0 {
+		insts, resp, err := client.Apps.ListInstallations(ctx, &github.ListOptions{
+			Page:    page,
+			PerPage: 100,
+		})
+		if err != nil {
+			return nil, err
+		}
+
+		installations = append(installations, insts...)
+		page = resp.NextPage
+	}
+
+	if len(installations) != 1 {
+		return nil, fmt.Errorf("found %d installations, an installation id must be provided", len(installations))
+	}
+
+	return installations[0], nil
+}
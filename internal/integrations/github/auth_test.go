@@ -0,0 +1,97 @@
+package github_test
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/pem"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/effxhq/vcs-connect/internal/integrations"
+	"github.com/effxhq/vcs-connect/internal/integrations/github"
+	"github.com/effxhq/vcs-connect/internal/logger"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/urfave/cli/v2"
+
+	"go.uber.org/zap"
+)
+
+func TestInstallationAuth(t *testing.T) {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	require.NoError(t, err)
+
+	privateKey := pem.EncodeToMemory(&pem.Block{
+		Type:  "RSA PRIVATE KEY",
+		Bytes: x509.MarshalPKCS1PrivateKey(key),
+	})
+
+	var failing int32
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/api/v3/app/installations/1", func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, `{"id": 1}`)
+	})
+	mux.HandleFunc("/app/installations/1/access_tokens", func(w http.ResponseWriter, r *http.Request) {
+		if atomic.LoadInt32(&failing) == 1 {
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+
+		// tokens close to expiring are minted again whenever they are used
+		w.WriteHeader(http.StatusCreated)
+		fmt.Fprintf(w, `{"token": "installation_token", "expires_at": %q}`,
+			time.Now().Add(30*time.Second).Format(time.RFC3339))
+	})
+
+	server := httptest.NewServer(mux)
+	defer server.Close()
+
+	ctx := logger.AttachToContext(context.Background(), zap.NewNop())
+
+	integration, err := github.NewIntegration(ctx, &github.Configuration{
+		BaseURL:           server.URL + "/",
+		UploadURL:         server.URL + "/",
+		AppID:             1,
+		AppInstallationID: 1,
+		AppPrivateKey:     string(privateKey),
+		Organizations:     cli.NewStringSlice(),
+		Users:             cli.NewStringSlice(),
+	}, integrations.Filters{})
+	require.NoError(t, err)
+
+	auth := integration.AuthMethod()
+	require.NoError(t, auth.Refresh(ctx))
+	require.NoError(t, auth.Err())
+
+	r := httptest.NewRequest(http.MethodGet, "/effxhq/vcs-connect.git/info/refs", nil)
+	auth.SetAuth(r)
+	username, password, ok := r.BasicAuth()
+	require.True(t, ok)
+	require.Equal(t, "x-access-token", username)
+	require.Equal(t, "installation_token", password)
+
+	// failing to mint a token mid-clone sends the request without credentials,
+	// and is kept to fail the clone with
+	atomic.StoreInt32(&failing, 1)
+
+	r = httptest.NewRequest(http.MethodGet, "/effxhq/vcs-connect.git/info/refs", nil)
+	auth.SetAuth(r)
+	_, _, ok = r.BasicAuth()
+	require.False(t, ok)
+	require.Error(t, auth.Err())
+	require.Error(t, auth.Refresh(ctx))
+
+	// the failure is cleared once a token is minted again
+	atomic.StoreInt32(&failing, 0)
+
+	require.NoError(t, auth.Refresh(ctx))
+	require.NoError(t, auth.Err())
+}
@@ -14,11 +14,46 @@ type Configuration struct {
 	UserName            string
 	PersonalAccessToken string
 	Organizations       *cli.StringSlice
+
+	IncludeUserRepositories bool
+	Users                   *cli.StringSlice
+	Affiliation             string
+
+	// CodeSearch only discovers repositories the code search api finds effx.yaml files in.
+	CodeSearch bool
+	// NoClone reads effx.yaml files through the API instead of cloning repositories.
+	NoClone bool
+
+	AppID             int64
+	AppInstallationID int64
+	AppPrivateKey     string
+	AppPrivateKeyFile string
+}
+
+// IsApp returns true when the configuration authenticates as a GitHub App
+// instead of a user with a personal access token.
+func (c *Configuration) IsApp() bool {
+	return c.AppID != 0
 }
 
 // Validate ensures the configuration provided contains the required information.
 func (c *Configuration) Validate() error {
-	if c.UserName == "" {
+	if c.CodeSearch && (c.IncludeUserRepositories || c.Affiliation != "") {
+		return fmt.Errorf("code search does not apply to user and affiliated repositories")
+	}
+
+	if c.IsApp() {
+		if c.IncludeUserRepositories || c.Affiliation != "" {
+			return fmt.Errorf("user and affiliated repositories require a personal access token")
+		} else if c.CodeSearch && (c.Organizations == nil || len(c.Organizations.Value()) == 0) {
+			return fmt.Errorf("code search requires organizations when authenticating as a GitHub App")
+		} else if c.AppPrivateKey == "" && c.AppPrivateKeyFile == "" {
+			return fmt.Errorf("a private key must be provided for the GitHub App")
+		} else if c.AppPrivateKey != "" && c.AppPrivateKeyFile != "" {
+			return fmt.Errorf("only one of private key or private key file may be provided")
+		}
+		return nil
+	} else if c.UserName == "" {
 		return fmt.Errorf("a username must be provided")
 	} else if c.PersonalAccessToken == "" {
 		return fmt.Errorf("a personal access token must be provided")
@@ -30,6 +65,7 @@ func (c *Configuration) Validate() error {
 func DefaultConfigWithFlags() (*Configuration, []cli.Flag) {
 	cfg := &Configuration{
 		Organizations: cli.NewStringSlice(),
+		Users:         cli.NewStringSlice(),
 	}
 
 	flags := []cli.Flag{
@@ -68,6 +104,69 @@ func DefaultConfigWithFlags() (*Configuration, []cli.Flag) {
 			Value:       cfg.Organizations,
 			EnvVars:     []string{"GITHUB_ORGANIZATIONS"},
 		},
+		&cli.BoolFlag{
+			Name:        "github-include-user-repositories",
+			Usage:       "include repositories owned by the authenticated user",
+			Destination: &(cfg.IncludeUserRepositories),
+			Value:       cfg.IncludeUserRepositories,
+			EnvVars:     []string{"GITHUB_INCLUDE_USER_REPOSITORIES"},
+		},
+		&cli.StringSliceFlag{
+			Name:        "github-users",
+			Usage:       "include repositories owned by the listed GitHub users",
+			Destination: cfg.Users,
+			Value:       cfg.Users,
+			EnvVars:     []string{"GITHUB_USERS"},
+		},
+		&cli.StringFlag{
+			Name:        "github-affiliation",
+			Usage:       "include repositories the token can access by affiliation (owner, collaborator, organization_member)",
+			Destination: &(cfg.Affiliation),
+			Value:       cfg.Affiliation,
+			EnvVars:     []string{"GITHUB_AFFILIATION"},
+		},
+		&cli.BoolFlag{
+			Name:        "github-code-search",
+			Usage:       "only index repositories of organizations and users where code search finds effx.yaml files",
+			Destination: &(cfg.CodeSearch),
+			Value:       cfg.CodeSearch,
+			EnvVars:     []string{"GITHUB_CODE_SEARCH"},
+		},
+		&cli.BoolFlag{
+			Name:        "github-no-clone",
+			Usage:       "read effx.yaml files through the GitHub API instead of cloning, when language and service detection are disabled",
+			Destination: &(cfg.NoClone),
+			Value:       cfg.NoClone,
+			EnvVars:     []string{"GITHUB_NO_CLONE"},
+		},
+		&cli.Int64Flag{
+			Name:        "github-app-id",
+			Usage:       "authenticate as the GitHub App with this id instead of a personal access token",
+			Destination: &(cfg.AppID),
+			Value:       cfg.AppID,
+			EnvVars:     []string{"GITHUB_APP_ID"},
+		},
+		&cli.Int64Flag{
+			Name:        "github-app-installation-id",
+			Usage:       "the installation of the GitHub App to use, discovered when omitted",
+			Destination: &(cfg.AppInstallationID),
+			Value:       cfg.AppInstallationID,
+			EnvVars:     []string{"GITHUB_APP_INSTALLATION_ID"},
+		},
+		&cli.StringFlag{
+			Name:        "github-app-private-key",
+			Usage:       "the PEM encoded private key of the GitHub App",
+			Destination: &(cfg.AppPrivateKey),
+			Value:       cfg.AppPrivateKey,
+			EnvVars:     []string{"GITHUB_APP_PRIVATE_KEY"},
+		},
+		&cli.StringFlag{
+			Name:        "github-app-private-key-file",
+			Usage:       "path to the PEM encoded private key of the GitHub App",
+			Destination: &(cfg.AppPrivateKeyFile),
+			Value:       cfg.AppPrivateKeyFile,
+			EnvVars:     []string{"GITHUB_APP_PRIVATE_KEY_FILE"},
+		},
 	}
 
 	return cfg, flags
@@ -2,11 +2,22 @@ package github
 
 import (
 	"context"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
 
+	"github.com/effxhq/vcs-connect/internal/filter"
+	"github.com/effxhq/vcs-connect/internal/integrations"
 	"github.com/effxhq/vcs-connect/internal/logger"
+	"github.com/effxhq/vcs-connect/internal/metrics"
 	"github.com/effxhq/vcs-connect/internal/model"
+	"github.com/effxhq/vcs-connect/internal/tracing"
 
-	"github.com/google/go-github/v20/github"
+	"github.com/bradleyfalzon/ghinstallation"
+
+	"github.com/google/go-github/v29/github"
 
 	"github.com/pkg/errors"
 
@@ -15,42 +26,183 @@ import (
 	"golang.org/x/oauth2"
 )
 
+func newClient(config *Configuration, httpClient *http.Client) (*github.Client, error) {
+	if config.BaseURL != "" && config.UploadURL != "" {
+		return github.NewEnterpriseClient(config.BaseURL, config.UploadURL, httpClient)
+	}
+	return github.NewClient(httpClient), nil
+}
+
 // NewIntegration returns the Integration responsible for communicating with GitHub.
 // Before construction, the Configuration is validated to ensure it contains the
 // proper information.
-func NewIntegration(ctx context.Context, config *Configuration) (*Integration, error) {
+func NewIntegration(ctx context.Context, config *Configuration, filter integrations.Filter) (*Integration, error) {
 	if err := config.Validate(); err != nil {
 		return nil, err
 	}
 
-	tokenSource := oauth2.StaticTokenSource(&oauth2.Token{
-		AccessToken: config.PersonalAccessToken,
-	})
-	httpClient := oauth2.NewClient(ctx, tokenSource)
+	var httpClient *http.Client
+	var installation *ghinstallation.Transport
 
-	var client *github.Client
-	var err error
+	if config.IsApp() {
+		appsTransport, err := newAppsTransport(config)
+		if err != nil {
+			return nil, errors.Wrap(err, "failed to setup GitHub App authentication")
+		}
 
-	if config.BaseURL != "" && config.UploadURL != "" {
-		client, err = github.NewEnterpriseClient(config.BaseURL, config.UploadURL, httpClient)
+		appClient, err := newClient(config, &http.Client{Transport: appsTransport})
+		if err != nil {
+			return nil, err
+		}
+
+		inst, err := discoverInstallation(ctx, appClient, config)
+		if err != nil {
+			return nil, errors.Wrap(err, "failed to discover GitHub App installation")
+		}
+
+		installation = ghinstallation.NewFromAppsTransport(appsTransport, inst.GetID())
+		httpClient = &http.Client{Transport: installation}
 	} else {
-		client = github.NewClient(httpClient)
+		tokenSource := oauth2.StaticTokenSource(&oauth2.Token{
+			AccessToken: config.PersonalAccessToken,
+		})
+		httpClient = oauth2.NewClient(ctx, tokenSource)
 	}
 
+	httpClient = &http.Client{Transport: metrics.Transport("github", tracing.Transport(httpClient.Transport))}
+
+	client, err := newClient(config, httpClient)
 	if err != nil {
 		return nil, err
 	}
 
 	return &Integration{
-		client: client,
-		config: config,
+		client:       client,
+		config:       config,
+		installation: installation,
+		filter:       filter,
 	}, nil
 }
 
 // Integration encapsulates the logic for integrating data from GitHub.
 type Integration struct {
-	client *github.Client
-	config *Configuration
+	client       *github.Client
+	config       *Configuration
+	installation *ghinstallation.Transport
+	filter       integrations.Filter
+}
+
+// AuthMethod returns the authentication used to clone repositories when running
+// as a GitHub App. It returns nil when authenticating with a personal access token.
+func (i *Integration) AuthMethod() *InstallationAuth {
+	if i.installation == nil {
+		return nil
+	}
+	return &InstallationAuth{transport: i.installation}
+}
+
+// preview media types enabling the topics and template fields of repositories
+// on GitHub Enterprise versions where they have not launched yet
+const (
+	mediaTypeTopicsPreview             = "application/vnd.github.mercy-preview+json"
+	mediaTypeRepositoryTemplatePreview = "application/vnd.github.baptiste-preview+json"
+	mediaTypeIntegrationPreview        = "application/vnd.github.machine-man-preview+json"
+)
+
+// ReportedProperties are the properties GitHub reports for repositories.
+const ReportedProperties = filter.Archived | filter.Forks | filter.Templates | filter.Mirrors |
+	filter.Empty | filter.Visibility | filter.Topics
+
+// repository adds the fields go-github does not decode to its repositories.
+type repository struct {
+	*github.Repository
+	// Visibility is public, internal or private, and is missing on older GitHub Enterprise versions.
+	Visibility *string `json:"visibility,omitempty"`
+}
+
+// get requests the api endpoint, decoding the response into v. Repositories are
+// requested through get rather than go-github, so their visibility is decoded.
+func (i *Integration) get(ctx context.Context, endpoint string, query url.Values, v interface{}, accept ...string) (*github.Response, error) {
+	if len(query) > 0 {
+		endpoint += "?" + query.Encode()
+	}
+
+	req, err := i.client.NewRequest(http.MethodGet, endpoint, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	accept = append(accept, mediaTypeTopicsPreview, mediaTypeRepositoryTemplatePreview)
+	req.Header.Set("Accept", strings.Join(accept, ", "))
+
+	return i.client.Do(ctx, req, v)
+}
+
+// list requests a page of up to 100 results from the api endpoint.
+func (i *Integration) list(ctx context.Context, endpoint string, query url.Values, page int, v interface{}, accept ...string) (*github.Response, error) {
+	paged := url.Values{}
+	for key, values := range query {
+		paged[key] = values
+	}
+	paged.Set("page", strconv.Itoa(page))
+	paged.Set("per_page", "100")
+
+	return i.get(ctx, endpoint, paged, v, accept...)
+}
+
+func toRepository(repo *repository) *model.Repository {
+	// repositories only report whether they are private when visibility is missing
+	visibility := "public"
+	if repo.Visibility != nil {
+		visibility = *repo.Visibility
+	} else if repo.GetPrivate() {
+		visibility = "private"
+	}
+
+	// sizes are rounded to kilobytes, so Empty is confirmed by isEmpty before filtering
+	return &model.Repository{
+		CloneURL:      repo.GetCloneURL(),
+		SSHURL:        repo.GetSSHURL(),
+		Tags:          map[string]string{},
+		Annotations:   map[string]string{},
+		FullName:      repo.GetFullName(),
+		Owner:         repo.GetOwner().GetLogin(),
+		DefaultBranch: repo.GetDefaultBranch(),
+		WebURL:        repo.GetHTMLURL(),
+		Visibility:    visibility,
+		Topics:        repo.Topics,
+		Archived:      repo.GetArchived(),
+		Fork:          repo.GetFork(),
+		Template:      repo.GetIsTemplate(),
+		Mirror:        repo.GetMirrorURL() != "",
+		Empty:         repo.GetSize() == 0,
+		PushedAt:      repo.GetPushedAt().Time,
+	}
+}
+
+// repoName returns the name of the repository without its owner.
+func repoName(repository *model.Repository) string {
+	return strings.TrimPrefix(repository.FullName, repository.Owner+"/")
+}
+
+// source returns a Source reading files of the repository through the API.
+func (i *Integration) source(repository *model.Repository) *source {
+	return &source{
+		client: i.client,
+		owner:  repository.Owner,
+		repo:   repoName(repository),
+		branch: repository.DefaultBranch,
+	}
+}
+
+// isEmpty confirms whether a repository reporting no size has no commits, as tiny
+// repositories also report a size of zero. Listing the commits of an empty repository
+// fails with 409 Conflict, while any other failure leaves the repository to be indexed.
+func (i *Integration) isEmpty(ctx context.Context, repository *model.Repository) bool {
+	_, resp, err := i.client.Repositories.ListCommits(ctx, repository.Owner, repoName(repository), &github.CommitsListOptions{
+		ListOptions: github.ListOptions{PerPage: 1},
+	})
+	return err != nil && resp != nil && resp.StatusCode == http.StatusConflict
 }
 
 func (i *Integration) discoverOrganizations(ctx context.Context) ([]string, error) {
@@ -88,23 +240,15 @@ func (i *Integration) discoverRepositories(ctx context.Context, organization str
 
 	page := 1
 	for page > 0 {
-		repos, resp, err := i.client.Repositories.ListByOrg(ctx, organization, &github.RepositoryListByOrgOptions{
-			ListOptions: github.ListOptions{
-				Page:    page,
-				PerPage: 100,
-			},
-		})
+		var repos []*repository
+		resp, err := i.list(ctx, fmt.Sprintf("orgs/%s/repos", organization), nil, page, &repos)
 		if err != nil {
 			return nil, err
 		}
 
 		results := make([]*model.Repository, len(repos))
 		for i, repo := range repos {
-			results[i] = &model.Repository{
-				CloneURL:    repo.GetCloneURL(),
-				Tags:        map[string]string{},
-				Annotations: map[string]string{},
-			}
+			results[i] = toRepository(repo)
 		}
 
 		repositories = append(repositories, results...)
@@ -114,39 +258,188 @@ func (i *Integration) discoverRepositories(ctx context.Context, organization str
 	return repositories, nil
 }
 
-// Run feeds the data channel with results it discovers from GitHub
-func (i *Integration) Run(ctx context.Context, data chan *model.Repository) error {
-	log := logger.MustGetFromContext(ctx)
+// discoverUserRepositories lists repositories through the user endpoints. An empty user
+// lists repositories of the authenticated user matching the provided affiliation.
+func (i *Integration) discoverUserRepositories(ctx context.Context, user, affiliation string) ([]*model.Repository, error) {
+	repositories := make([]*model.Repository, 0)
 
-	organizations, err := i.discoverOrganizations(ctx)
-	if err != nil {
-		return errors.Wrap(err, "failed to discover organizations from GitHub")
+	endpoint := fmt.Sprintf("users/%s/repos", user)
+	query := url.Values{"type": {"owner"}}
+	if user == "" {
+		endpoint = "user/repos"
+		query = url.Values{"affiliation": {affiliation}}
 	}
 
-	for _, organization := range organizations {
-		log.Info("discovering repositories",
-			zap.String("organization", organization))
+	page := 1
+	for page > 0 {
+		var repos []*repository
+		resp, err := i.list(ctx, endpoint, query, page, &repos)
+		if err != nil {
+			return nil, err
+		}
+
+		results := make([]*model.Repository, len(repos))
+		for i, repo := range repos {
+			results[i] = toRepository(repo)
+		}
+
+		repositories = append(repositories, results...)
+		page = resp.NextPage
+	}
+
+	return repositories, nil
+}
+
+// discoverInstallationRepositories lists every repository the GitHub App installation can access.
+func (i *Integration) discoverInstallationRepositories(ctx context.Context) ([]*model.Repository, error) {
+	repositories := make([]*model.Repository, 0)
 
-		repositories, err := i.discoverRepositories(ctx, organization)
+	page := 1
+	for page > 0 {
+		var repos struct {
+			Repositories []*repository `json:"repositories"`
+		}
+		resp, err := i.list(ctx, "installation/repositories", nil, page, &repos, mediaTypeIntegrationPreview)
 		if err != nil {
-			log.Error("failed to discover repositories",
-				zap.String("organization", organization),
-				zap.Error(err))
-			continue
+			return nil, err
+		}
+
+		results := make([]*model.Repository, len(repos.Repositories))
+		for i, repo := range repos.Repositories {
+			results[i] = toRepository(repo)
 		}
 
-		// push to consumers or stop if cancelled
+		repositories = append(repositories, results...)
+		page = resp.NextPage
+	}
+
+	return repositories, nil
+}
+
+// Run feeds the data channel with results it discovers from GitHub
+func (i *Integration) Run(ctx context.Context, data chan *model.Repository) error {
+	log := logger.MustGetFromContext(ctx)
+
+	// repositories can be reached through organizations, users and affiliations,
+	// so track which were already pushed to avoid consuming them more than once.
+	seen := make(map[string]bool)
+
+	// push to consumers, returning false if cancelled
+	publish := func(repositories []*model.Repository) bool {
 		for _, repository := range repositories {
+			if seen[repository.CloneURL] {
+				continue
+			}
+			seen[repository.CloneURL] = true
+
+			if repository.Empty {
+				repository.Empty = i.isEmpty(ctx, repository)
+			}
+
+			if i.config.NoClone {
+				repository.Source = i.source(repository)
+			}
+
+			if reason := i.filter.Reason(repository); reason != "" {
+				log.Info("skipping repository",
+					zap.String("repository", repository.CloneURL),
+					zap.String("reason", reason))
+				continue
+			}
+
 			log.Info("processing repository",
 				zap.String("repository", repository.CloneURL))
 
 			select {
 			case <-ctx.Done():
-				return nil
+				return false
 			case data <- repository:
 				continue
 			}
 		}
+		return true
+	}
+
+	// without configured organizations, an app is limited to the repositories it was granted
+	if i.installation != nil && len(i.config.Organizations.Value()) == 0 {
+		log.Info("discovering repositories for installation")
+
+		repositories, err := i.discoverInstallationRepositories(ctx)
+		if err != nil {
+			return errors.Wrap(err, "failed to discover repositories from GitHub App installation")
+		}
+
+		if !publish(repositories) {
+			return nil
+		}
+	} else {
+		organizations, err := i.discoverOrganizations(ctx)
+		if err != nil {
+			return errors.Wrap(err, "failed to discover organizations from GitHub")
+		}
+
+		for _, organization := range organizations {
+			log.Info("discovering repositories",
+				zap.String("organization", organization))
+
+			repositories, err := i.discoverCandidates(ctx, log, "org:"+organization, func() ([]*model.Repository, error) {
+				return i.discoverRepositories(ctx, organization)
+			})
+			if err != nil {
+				log.Error("failed to discover repositories",
+					zap.String("organization", organization),
+					zap.Error(err))
+				continue
+			}
+
+			if !publish(repositories) {
+				return nil
+			}
+		}
+	}
+
+	affiliations := make([]string, 0)
+	if i.config.IncludeUserRepositories {
+		affiliations = append(affiliations, "owner")
+	}
+	if i.config.Affiliation != "" {
+		affiliations = append(affiliations, i.config.Affiliation)
+	}
+
+	for _, affiliation := range affiliations {
+		log.Info("discovering repositories",
+			zap.String("affiliation", affiliation))
+
+		repositories, err := i.discoverUserRepositories(ctx, "", affiliation)
+		if err != nil {
+			log.Error("failed to discover repositories",
+				zap.String("affiliation", affiliation),
+				zap.Error(err))
+			continue
+		}
+
+		if !publish(repositories) {
+			return nil
+		}
+	}
+
+	for _, user := range i.config.Users.Value() {
+		log.Info("discovering repositories",
+			zap.String("user", user))
+
+		repositories, err := i.discoverCandidates(ctx, log, "user:"+user, func() ([]*model.Repository, error) {
+			return i.discoverUserRepositories(ctx, user, "")
+		})
+		if err != nil {
+			log.Error("failed to discover repositories",
+				zap.String("user", user),
+				zap.Error(err))
+			continue
+		}
+
+		if !publish(repositories) {
+			return nil
+		}
 	}
 
 	return nil
@@ -0,0 +1,181 @@
+package github_test
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+
+	"github.com/effxhq/vcs-connect/internal/integrations"
+	"github.com/effxhq/vcs-connect/internal/integrations/github"
+	"github.com/effxhq/vcs-connect/internal/logger"
+	"github.com/effxhq/vcs-connect/internal/model"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/urfave/cli/v2"
+
+	"go.uber.org/zap"
+)
+
+// run discovers repositories of the effxhq organization from a GitHub Enterprise
+// api served by the provided mux.
+func run(t *testing.T, mux *http.ServeMux, configure func(cfg *github.Configuration)) []*model.Repository {
+	server := httptest.NewServer(mux)
+	defer server.Close()
+
+	cfg := &github.Configuration{
+		BaseURL:             server.URL + "/",
+		UploadURL:           server.URL + "/",
+		UserName:            "effx",
+		PersonalAccessToken: "token",
+		Organizations:       cli.NewStringSlice("effxhq"),
+		Users:               cli.NewStringSlice(),
+	}
+	if configure != nil {
+		configure(cfg)
+	}
+
+	ctx := logger.AttachToContext(context.Background(), zap.NewNop())
+
+	integration, err := github.NewIntegration(ctx, cfg, integrations.Filters{})
+	require.NoError(t, err)
+
+	data := make(chan *model.Repository, 100)
+	require.NoError(t, integration.Run(ctx, data))
+	close(data)
+
+	repositories := make([]*model.Repository, 0)
+	for repository := range data {
+		repositories = append(repositories, repository)
+	}
+	return repositories
+}
+
+func repositoryJSON(name string, size int) string {
+	return fmt.Sprintf(`{"name": %q, "full_name": "effxhq/%s", "clone_url": "https://github.com/effxhq/%s.git",
+		"default_branch": "main", "size": %d, "owner": {"login": "effxhq"}}`, name, name, name, size)
+}
+
+func TestIntegration_Empty(t *testing.T) {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/api/v3/orgs/effxhq/repos", func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprintf(w, "[%s, %s, %s]", repositoryJSON("empty", 0), repositoryJSON("tiny", 0), repositoryJSON("large", 2048))
+	})
+	mux.HandleFunc("/api/v3/repos/effxhq/empty/commits", func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusConflict)
+		fmt.Fprint(w, `{"message": "Git Repository is empty."}`)
+	})
+	mux.HandleFunc("/api/v3/repos/effxhq/tiny/commits", func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, `[{"sha": "6dcb09b5b57875f334f61aebed695e2e4193db5e"}]`)
+	})
+
+	repositories := run(t, mux, nil)
+	require.Len(t, repositories, 3)
+
+	empty := make(map[string]bool)
+	for _, repository := range repositories {
+		empty[repository.FullName] = repository.Empty
+	}
+
+	require.Equal(t, map[string]bool{
+		"effxhq/empty": true,
+		"effxhq/tiny":  false,
+		"effxhq/large": false,
+	}, empty)
+}
+
+func ownedRepositoryJSON(owner, name string) string {
+	return fmt.Sprintf(`{"name": %q, "full_name": "%s/%s", "clone_url": "https://github.com/%s/%s.git",
+		"default_branch": "main", "size": 1024, "owner": {"login": %q}}`, name, owner, name, owner, name, owner)
+}
+
+func TestIntegration_RunUsersAndAffiliations(t *testing.T) {
+	var mu sync.Mutex
+	requests := make([]string, 0)
+
+	mux := http.NewServeMux()
+	record := func(handler http.HandlerFunc) http.HandlerFunc {
+		return func(w http.ResponseWriter, r *http.Request) {
+			mu.Lock()
+			requests = append(requests, r.URL.Path+"?"+r.URL.RawQuery)
+			mu.Unlock()
+
+			handler(w, r)
+		}
+	}
+
+	mux.HandleFunc("/api/v3/orgs/effxhq/repos", record(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprintf(w, "[%s]", ownedRepositoryJSON("effxhq", "api"))
+	}))
+	mux.HandleFunc("/api/v3/user/repos", record(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Query().Get("affiliation") {
+		case "owner":
+			fmt.Fprintf(w, "[%s]", ownedRepositoryJSON("effx", "dotfiles"))
+		case "collaborator":
+			// the organization repository is reached a second time
+			fmt.Fprintf(w, "[%s, %s]", ownedRepositoryJSON("effxhq", "api"), ownedRepositoryJSON("partner", "sdk"))
+		}
+	}))
+	mux.HandleFunc("/api/v3/users/octocat/repos", record(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Query().Get("page") == "2" {
+			fmt.Fprintf(w, "[%s]", ownedRepositoryJSON("octocat", "world"))
+			return
+		}
+		w.Header().Set("Link", fmt.Sprintf(`<http://%s/api/v3/users/octocat/repos?page=2>; rel="next"`, r.Host))
+		fmt.Fprintf(w, "[%s]", ownedRepositoryJSON("octocat", "hello"))
+	}))
+
+	repositories := run(t, mux, func(cfg *github.Configuration) {
+		cfg.IncludeUserRepositories = true
+		cfg.Affiliation = "collaborator"
+		cfg.Users = cli.NewStringSlice("octocat")
+	})
+
+	// repositories reached through more than one route are only consumed once
+	fullNames := make([]string, len(repositories))
+	for i, repository := range repositories {
+		fullNames[i] = repository.FullName
+	}
+	require.Equal(t, []string{
+		"effxhq/api",
+		"effx/dotfiles",
+		"partner/sdk",
+		"octocat/hello",
+		"octocat/world",
+	}, fullNames)
+
+	// the authenticated user is listed by affiliation, while other users are
+	// limited to the repositories they own
+	require.Equal(t, []string{
+		"/api/v3/orgs/effxhq/repos?page=1&per_page=100",
+		"/api/v3/user/repos?affiliation=owner&page=1&per_page=100",
+		"/api/v3/user/repos?affiliation=collaborator&page=1&per_page=100",
+		"/api/v3/users/octocat/repos?page=1&per_page=100&type=owner",
+		"/api/v3/users/octocat/repos?page=2&per_page=100&type=owner",
+	}, requests)
+}
+
+func TestIntegration_RunVisibility(t *testing.T) {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/api/v3/orgs/effxhq/repos", func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, `[
+			{"full_name": "effxhq/public", "clone_url": "https://github.com/effxhq/public.git", "size": 1, "visibility": "public"},
+			{"full_name": "effxhq/internal", "clone_url": "https://github.com/effxhq/internal.git", "size": 1, "private": true, "visibility": "internal"},
+			{"full_name": "effxhq/private", "clone_url": "https://github.com/effxhq/private.git", "size": 1, "private": true}
+		]`)
+	})
+
+	// older GitHub Enterprise versions only report whether a repository is private
+	visibility := make(map[string]string)
+	for _, repository := range run(t, mux, nil) {
+		visibility[repository.FullName] = repository.Visibility
+	}
+	require.Equal(t, map[string]string{
+		"effxhq/public":   "public",
+		"effxhq/internal": "internal",
+		"effxhq/private":  "private",
+	}, visibility)
+}
@@ -0,0 +1,96 @@
+package github
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/effxhq/vcs-connect/internal/model"
+
+	"github.com/google/go-github/v29/github"
+
+	"go.uber.org/zap"
+)
+
+// the code search api returns at most this many results for a query
+const maxSearchResults = 1000
+
+// searchQueries match effx.yaml files along with prefixed ones such as api.effx.yaml.
+// The filename qualifier matches any file named with the word, so results are
+// filtered afterwards, and each extension is searched for separately as
+// qualifiers cannot be combined with OR.
+var searchQueries = []string{"filename:effx extension:yaml", "filename:effx extension:yml"}
+
+// discoverRepositoriesBySearch lists the repositories containing effx.yaml files
+// using the code search api, scoped by a qualifier such as org:effxhq.
+func (i *Integration) discoverRepositoriesBySearch(ctx context.Context, qualifier string) ([]*model.Repository, error) {
+	seen := make(map[string]bool)
+	fullNames := make([]string, 0)
+
+	for _, filenames := range searchQueries {
+		query := fmt.Sprintf("%s %s", filenames, qualifier)
+
+		page := 1
+		for page > 0 {
+			result, resp, err := i.client.Search.Code(ctx, query, &github.SearchOptions{
+				ListOptions: github.ListOptions{
+					Page:    page,
+					PerPage: 100,
+				},
+			})
+			if err != nil {
+				return nil, err
+			} else if result.GetIncompleteResults() || result.GetTotal() > maxSearchResults {
+				return nil, fmt.Errorf("code search returned incomplete results for %q", query)
+			}
+
+			for _, code := range result.CodeResults {
+				fullName := code.GetRepository().GetFullName()
+				if fullName != "" && !seen[fullName] && model.IsEffxYAML(code.GetPath()) {
+					seen[fullName] = true
+					fullNames = append(fullNames, fullName)
+				}
+			}
+
+			page = resp.NextPage
+		}
+	}
+
+	// search results only describe repositories partially, so fetch each in full
+	repositories := make([]*model.Repository, 0, len(fullNames))
+	for _, fullName := range fullNames {
+		parts := strings.SplitN(fullName, "/", 2)
+
+		repo := &repository{}
+		_, err := i.get(ctx, fmt.Sprintf("repos/%s/%s", parts[0], parts[1]), nil, repo)
+		if err != nil {
+			return nil, err
+		}
+
+		repositories = append(repositories, toRepository(repo))
+	}
+
+	return repositories, nil
+}
+
+// discoverCandidates lists repositories through code search when enabled, falling
+// back to listing every repository when the search fails or is incomplete.
+func (i *Integration) discoverCandidates(
+	ctx context.Context,
+	log *zap.Logger,
+	qualifier string,
+	list func() ([]*model.Repository, error),
+) ([]*model.Repository, error) {
+	if i.config.CodeSearch {
+		repositories, err := i.discoverRepositoriesBySearch(ctx, qualifier)
+		if err == nil {
+			return repositories, nil
+		}
+
+		log.Warn("failed to search for effx.yaml files, listing every repository instead",
+			zap.String("qualifier", qualifier),
+			zap.Error(err))
+	}
+
+	return list()
+}
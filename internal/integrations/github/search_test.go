@@ -0,0 +1,120 @@
+package github_test
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/effxhq/vcs-connect/internal/integrations/github"
+	"github.com/effxhq/vcs-connect/internal/model"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/urfave/cli/v2"
+)
+
+func codeResultJSON(name, path string) string {
+	return fmt.Sprintf(`{"path": %q, "repository": {"full_name": "effxhq/%s"}}`, path, name)
+}
+
+func fullNames(repositories []*model.Repository) []string {
+	names := make([]string, 0, len(repositories))
+	for _, repository := range repositories {
+		names = append(names, repository.FullName)
+	}
+	return names
+}
+
+func TestIntegration_CodeSearch(t *testing.T) {
+	queries := make([]string, 0)
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/api/v3/search/code", func(w http.ResponseWriter, r *http.Request) {
+		query := r.URL.Query().Get("q")
+		queries = append(queries, query)
+
+		switch {
+		case strings.Contains(query, "extension:yml"):
+			fmt.Fprint(w, `{"total_count": 1, "items": [`+codeResultJSON("api", "effx.yml")+`]}`)
+		case r.URL.Query().Get("page") == "2":
+			fmt.Fprint(w, `{"total_count": 3, "items": [`+codeResultJSON("web", "services/web.effx.yaml")+`]}`)
+		default:
+			// the filename qualifier also matches files which are not effx.yaml files
+			w.Header().Set("Link", fmt.Sprintf(`<http://%s/api/v3/search/code?page=2>; rel="next"`, r.Host))
+			fmt.Fprint(w, `{"total_count": 3, "items": [`+
+				codeResultJSON("api", "services/api/effx.yaml")+", "+
+				codeResultJSON("other", "effx-config.yaml")+`]}`)
+		}
+	})
+	for _, name := range []string{"api", "web"} {
+		name := name
+		mux.HandleFunc("/api/v3/repos/effxhq/"+name, func(w http.ResponseWriter, r *http.Request) {
+			fmt.Fprint(w, repositoryJSON(name, 2048))
+		})
+	}
+	mux.HandleFunc("/api/v3/orgs/effxhq/repos", func(w http.ResponseWriter, r *http.Request) {
+		t.Error("repositories should not be listed when the search succeeds")
+	})
+
+	repositories := run(t, mux, func(cfg *github.Configuration) {
+		cfg.CodeSearch = true
+	})
+
+	require.ElementsMatch(t, []string{"effxhq/api", "effxhq/web"}, fullNames(repositories))
+	require.Equal(t, []string{
+		"filename:effx extension:yaml org:effxhq",
+		"filename:effx extension:yaml org:effxhq",
+		"filename:effx extension:yml org:effxhq",
+	}, queries)
+}
+
+func TestIntegration_CodeSearchIncomplete(t *testing.T) {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/api/v3/search/code", func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, `{"total_count": 1, "incomplete_results": true, "items": [`+codeResultJSON("api", "effx.yaml")+`]}`)
+	})
+	mux.HandleFunc("/api/v3/orgs/effxhq/repos", func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprintf(w, "[%s, %s]", repositoryJSON("api", 2048), repositoryJSON("web", 2048))
+	})
+
+	// incomplete results could miss repositories, so every repository is listed instead
+	repositories := run(t, mux, func(cfg *github.Configuration) {
+		cfg.CodeSearch = true
+	})
+
+	require.ElementsMatch(t, []string{"effxhq/api", "effxhq/web"}, fullNames(repositories))
+}
+
+func TestConfiguration_CodeSearch(t *testing.T) {
+	token := func() *github.Configuration {
+		return &github.Configuration{
+			UserName:            "user",
+			PersonalAccessToken: "token",
+			Organizations:       cli.NewStringSlice(),
+			CodeSearch:          true,
+		}
+	}
+
+	require.NoError(t, token().Validate())
+
+	cfg := token()
+	cfg.IncludeUserRepositories = true
+	require.Error(t, cfg.Validate())
+
+	cfg = token()
+	cfg.Affiliation = "collaborator"
+	require.Error(t, cfg.Validate())
+
+	// installations are listed in full, so organizations must be searched instead
+	app := &github.Configuration{
+		AppID:         1,
+		AppPrivateKey: "key",
+		Organizations: cli.NewStringSlice(),
+		CodeSearch:    true,
+	}
+	require.Error(t, app.Validate())
+
+	app.Organizations = cli.NewStringSlice("effxhq")
+	require.NoError(t, app.Validate())
+}
@@ -0,0 +1,56 @@
+package github
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/effxhq/vcs-connect/internal/model"
+
+	"github.com/google/go-github/v29/github"
+)
+
+// source reads files through the git database api of GitHub.
+type source struct {
+	client *github.Client
+	owner  string
+	repo   string
+	branch string
+}
+
+var _ model.Source = &source{}
+
+func (s *source) Tree(ctx context.Context) (*model.Tree, error) {
+	if s.branch == "" {
+		return nil, fmt.Errorf("repository has no default branch")
+	}
+
+	branch, _, err := s.client.Repositories.GetBranch(ctx, s.owner, s.repo, s.branch)
+	if err != nil {
+		return nil, err
+	}
+	commit := branch.GetCommit().GetSHA()
+
+	tree, _, err := s.client.Git.GetTree(ctx, s.owner, s.repo, commit, true)
+	if err != nil {
+		return nil, err
+	} else if tree.GetTruncated() {
+		return nil, fmt.Errorf("repository has too many files to list through the api")
+	}
+
+	blobs := make(map[string]string)
+	for _, entry := range tree.Entries {
+		if entry.GetType() == "blob" {
+			blobs[entry.GetPath()] = entry.GetSHA()
+		}
+	}
+
+	return &model.Tree{
+		Commit: commit,
+		Blobs:  blobs,
+	}, nil
+}
+
+func (s *source) ReadBlob(ctx context.Context, id string) ([]byte, error) {
+	body, _, err := s.client.Git.GetBlobRaw(ctx, s.owner, s.repo, id)
+	return body, err
+}
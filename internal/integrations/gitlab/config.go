@@ -13,6 +13,13 @@ type Configuration struct {
 	UserName            string
 	PersonalAccessToken string
 	Groups              *cli.StringSlice
+	IncludeSubgroups    bool
+	IncludeUserProjects bool
+	Users               *cli.StringSlice
+	// CodeSearch only discovers projects of groups where blob search finds effx.yaml files.
+	CodeSearch bool
+	// NoClone reads effx.yaml files through the API instead of cloning repositories.
+	NoClone bool
 }
 
 // Validate ensures the configuration provided contains the required information.
@@ -28,7 +35,9 @@ func (c *Configuration) Validate() error {
 // DefaultConfigWithFlags returns configuration and flags specific to GitLab
 func DefaultConfigWithFlags() (*Configuration, []cli.Flag) {
 	cfg := &Configuration{
-		Groups: cli.NewStringSlice(),
+		Groups:           cli.NewStringSlice(),
+		IncludeSubgroups: true,
+		Users:            cli.NewStringSlice(),
 	}
 
 	flags := []cli.Flag{
@@ -60,6 +69,41 @@ func DefaultConfigWithFlags() (*Configuration, []cli.Flag) {
 			Value:       cfg.Groups,
 			EnvVars:     []string{"GITLAB_GROUPS"},
 		},
+		&cli.BoolFlag{
+			Name:        "gitlab-include-subgroups",
+			Usage:       "include projects from subgroups of each group",
+			Destination: &(cfg.IncludeSubgroups),
+			Value:       cfg.IncludeSubgroups,
+			EnvVars:     []string{"GITLAB_INCLUDE_SUBGROUPS"},
+		},
+		&cli.BoolFlag{
+			Name:        "gitlab-include-user-projects",
+			Usage:       "include projects in the namespace of the authenticated user",
+			Destination: &(cfg.IncludeUserProjects),
+			Value:       cfg.IncludeUserProjects,
+			EnvVars:     []string{"GITLAB_INCLUDE_USER_PROJECTS"},
+		},
+		&cli.StringSliceFlag{
+			Name:        "gitlab-users",
+			Usage:       "include projects in the namespaces of the listed GitLab users",
+			Destination: cfg.Users,
+			Value:       cfg.Users,
+			EnvVars:     []string{"GITLAB_USERS"},
+		},
+		&cli.BoolFlag{
+			Name:        "gitlab-code-search",
+			Usage:       "only index projects of groups where blob search finds effx.yaml files, requires advanced search",
+			Destination: &(cfg.CodeSearch),
+			Value:       cfg.CodeSearch,
+			EnvVars:     []string{"GITLAB_CODE_SEARCH"},
+		},
+		&cli.BoolFlag{
+			Name:        "gitlab-no-clone",
+			Usage:       "read effx.yaml files through the GitLab API instead of cloning, when language and service detection are disabled",
+			Destination: &(cfg.NoClone),
+			Value:       cfg.NoClone,
+			EnvVars:     []string{"GITLAB_NO_CLONE"},
+		},
 	}
 
 	return cfg, flags
@@ -2,9 +2,15 @@ package gitlab
 
 import (
 	"context"
+	"net/http"
+	"time"
 
+	"github.com/effxhq/vcs-connect/internal/filter"
+	"github.com/effxhq/vcs-connect/internal/integrations"
 	"github.com/effxhq/vcs-connect/internal/logger"
+	"github.com/effxhq/vcs-connect/internal/metrics"
 	"github.com/effxhq/vcs-connect/internal/model"
+	"github.com/effxhq/vcs-connect/internal/tracing"
 
 	"github.com/pkg/errors"
 
@@ -16,12 +22,14 @@ import (
 // NewIntegration returns the Integration responsible for communicating with GitLab.
 // Before construction, the Configuration is validated to ensure it contains the
 // proper information.
-func NewIntegration(ctx context.Context, config *Configuration) (*Integration, error) {
+func NewIntegration(ctx context.Context, config *Configuration, filter integrations.Filter) (*Integration, error) {
 	if err := config.Validate(); err != nil {
 		return nil, err
 	}
 
-	options := make([]gitlab.ClientOptionFunc, 0)
+	options := []gitlab.ClientOptionFunc{
+		gitlab.WithHTTPClient(&http.Client{Transport: metrics.Transport("gitlab", tracing.Transport(http.DefaultTransport))}),
+	}
 	if config.BaseURL != "" {
 		options = append(options, gitlab.WithBaseURL(config.BaseURL))
 	}
@@ -34,6 +42,7 @@ func NewIntegration(ctx context.Context, config *Configuration) (*Integration, e
 	return &Integration{
 		client: client,
 		config: config,
+		filter: filter,
 	}, nil
 }
 
@@ -41,6 +50,43 @@ func NewIntegration(ctx context.Context, config *Configuration) (*Integration, e
 type Integration struct {
 	client *gitlab.Client
 	config *Configuration
+	filter integrations.Filter
+}
+
+// ReportedProperties are the properties GitLab reports for projects. GitLab has
+// no template projects.
+const ReportedProperties = filter.Archived | filter.Forks | filter.Mirrors | filter.Empty |
+	filter.Visibility | filter.Topics
+
+func toRepository(repo *gitlab.Project) *model.Repository {
+	owner := ""
+	if repo.Namespace != nil {
+		owner = repo.Namespace.FullPath
+	}
+
+	var pushedAt time.Time
+	if repo.LastActivityAt != nil {
+		pushedAt = *repo.LastActivityAt
+	}
+
+	// projects without commits have no default branch
+	return &model.Repository{
+		CloneURL:      repo.HTTPURLToRepo,
+		SSHURL:        repo.SSHURLToRepo,
+		Tags:          map[string]string{},
+		Annotations:   map[string]string{},
+		FullName:      repo.PathWithNamespace,
+		Owner:         owner,
+		DefaultBranch: repo.DefaultBranch,
+		WebURL:        repo.WebURL,
+		Visibility:    string(repo.Visibility),
+		Topics:        repo.TagList,
+		Archived:      repo.Archived,
+		Fork:          repo.ForkedFromProject != nil,
+		Mirror:        repo.Mirror,
+		Empty:         repo.DefaultBranch == "",
+		PushedAt:      pushedAt,
+	}
 }
 
 func (i *Integration) discoverGroups(ctx context.Context) ([]string, error) {
@@ -58,12 +104,14 @@ func (i *Integration) discoverGroups(ctx context.Context) ([]string, error) {
 				Page:    page,
 				PerPage: 100,
 			},
-		})
+			// subgroups are only reached through their parent, and only when included
+			TopLevelOnly: gitlab.Bool(true),
+		}, gitlab.WithContext(ctx))
 		if err != nil {
 			return nil, err
 		}
 
-		results := make([]string, len(groups))
+		results := make([]string, len(grps))
 		for i, grp := range grps {
 			results[i] = grp.FullPath
 		}
@@ -75,8 +123,8 @@ func (i *Integration) discoverGroups(ctx context.Context) ([]string, error) {
 	return groups, nil
 }
 
-func (i *Integration) discoverRepositories(ctx context.Context, group string) ([]*model.Repository, error) {
-	repositories := make([]*model.Repository, 0)
+func (i *Integration) discoverRepositories(ctx context.Context, group string) ([]*gitlab.Project, error) {
+	repositories := make([]*gitlab.Project, 0)
 
 	page := 1
 	for page > 0 {
@@ -85,21 +133,53 @@ func (i *Integration) discoverRepositories(ctx context.Context, group string) ([
 				Page:    page,
 				PerPage: 100,
 			},
-		})
+			IncludeSubgroups: gitlab.Bool(i.config.IncludeSubgroups),
+		}, gitlab.WithContext(ctx))
 		if err != nil {
 			return nil, err
 		}
 
-		results := make([]*model.Repository, len(repos))
-		for i, repo := range repos {
-			results[i] = &model.Repository{
-				CloneURL:    repo.HTTPURLToRepo,
-				Tags:        map[string]string{},
-				Annotations: map[string]string{},
-			}
+		repositories = append(repositories, repos...)
+		page = resp.NextPage
+	}
+
+	return repositories, nil
+}
+
+func (i *Integration) discoverUsers(ctx context.Context) ([]interface{}, error) {
+	users := make([]interface{}, 0)
+
+	if i.config.IncludeUserProjects {
+		user, _, err := i.client.Users.CurrentUser(gitlab.WithContext(ctx))
+		if err != nil {
+			return nil, err
 		}
+		users = append(users, user.ID)
+	}
+
+	for _, user := range i.config.Users.Value() {
+		users = append(users, user)
+	}
+
+	return users, nil
+}
 
-		repositories = append(repositories, results...)
+func (i *Integration) discoverUserRepositories(ctx context.Context, user interface{}) ([]*gitlab.Project, error) {
+	repositories := make([]*gitlab.Project, 0)
+
+	page := 1
+	for page > 0 {
+		repos, resp, err := i.client.Projects.ListUserProjects(user, &gitlab.ListProjectsOptions{
+			ListOptions: gitlab.ListOptions{
+				Page:    page,
+				PerPage: 100,
+			},
+		}, gitlab.WithContext(ctx))
+		if err != nil {
+			return nil, err
+		}
+
+		repositories = append(repositories, repos...)
 		page = resp.NextPage
 	}
 
@@ -110,6 +190,47 @@ func (i *Integration) discoverRepositories(ctx context.Context, group string) ([
 func (i *Integration) Run(ctx context.Context, data chan *model.Repository) error {
 	log := logger.MustGetFromContext(ctx)
 
+	// projects can be reached through several groups and users, so track
+	// which were already pushed to avoid consuming them more than once.
+	seen := make(map[int]bool)
+
+	// push to consumers, returning false if cancelled
+	publish := func(projects []*gitlab.Project) bool {
+		for _, project := range projects {
+			if seen[project.ID] {
+				continue
+			}
+			seen[project.ID] = true
+
+			repository := toRepository(project)
+			if i.config.NoClone {
+				repository.Source = &source{
+					client:  i.client,
+					project: project.ID,
+					branch:  project.DefaultBranch,
+				}
+			}
+
+			if reason := i.filter.Reason(repository); reason != "" {
+				log.Info("skipping repository",
+					zap.String("repository", repository.CloneURL),
+					zap.String("reason", reason))
+				continue
+			}
+
+			log.Info("processing repository",
+				zap.String("repository", repository.CloneURL))
+
+			select {
+			case <-ctx.Done():
+				return false
+			case data <- repository:
+				continue
+			}
+		}
+		return true
+	}
+
 	groups, err := i.discoverGroups(ctx)
 	if err != nil {
 		return errors.Wrap(err, "failed to discover groups from GitLab")
@@ -119,7 +240,7 @@ func (i *Integration) Run(ctx context.Context, data chan *model.Repository) erro
 		log.Info("discovering repositories",
 			zap.String("group", group))
 
-		repositories, err := i.discoverRepositories(ctx, group)
+		projects, err := i.discoverCandidates(ctx, log, group)
 		if err != nil {
 			log.Error("failed to discover repositories",
 				zap.String("group", group),
@@ -127,17 +248,30 @@ func (i *Integration) Run(ctx context.Context, data chan *model.Repository) erro
 			continue
 		}
 
-		// push to consumers or stop if cancelled
-		for _, repository := range repositories {
-			log.Info("processing repository",
-				zap.String("repository", repository.CloneURL))
+		if !publish(projects) {
+			return nil
+		}
+	}
 
-			select {
-			case <-ctx.Done():
-				return nil
-			case data <- repository:
-				continue
-			}
+	users, err := i.discoverUsers(ctx)
+	if err != nil {
+		return errors.Wrap(err, "failed to discover users from GitLab")
+	}
+
+	for _, user := range users {
+		log.Info("discovering repositories",
+			zap.Any("user", user))
+
+		projects, err := i.discoverUserRepositories(ctx, user)
+		if err != nil {
+			log.Error("failed to discover repositories",
+				zap.Any("user", user),
+				zap.Error(err))
+			continue
+		}
+
+		if !publish(projects) {
+			return nil
 		}
 	}
 
@@ -0,0 +1,162 @@
+package gitlab_test
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+
+	"github.com/effxhq/vcs-connect/internal/integrations"
+	"github.com/effxhq/vcs-connect/internal/integrations/gitlab"
+	"github.com/effxhq/vcs-connect/internal/logger"
+	"github.com/effxhq/vcs-connect/internal/model"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/urfave/cli/v2"
+
+	"go.uber.org/zap"
+)
+
+func projectJSON(id int, namespace, name string) string {
+	return fmt.Sprintf(`{"id": %d, "path_with_namespace": "%s/%s", "http_url_to_repo": "https://gitlab.com/%s/%s.git",
+		"default_branch": "main", "namespace": {"full_path": %q}}`, id, namespace, name, namespace, name, namespace)
+}
+
+// fakeGitLab serves a top level effxhq group with a platform subgroup, along with
+// the namespaces of the authenticated user and of octocat.
+type fakeGitLab struct {
+	mu       sync.Mutex
+	requests []string
+}
+
+func (f *fakeGitLab) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	// the client probes the root of the api when it is constructed
+	if r.URL.Path == "/api/v4/" {
+		return
+	}
+
+	request := r.URL.Path
+	if r.URL.RawQuery != "" {
+		request += "?" + r.URL.RawQuery
+	}
+
+	f.mu.Lock()
+	f.requests = append(f.requests, request)
+	f.mu.Unlock()
+
+	query := r.URL.Query()
+
+	switch r.URL.Path {
+	case "/api/v4/groups":
+		if query.Get("top_level_only") == "true" {
+			fmt.Fprint(w, `[{"full_path": "effxhq"}]`)
+			return
+		}
+		fmt.Fprint(w, `[{"full_path": "effxhq"}, {"full_path": "effxhq/platform"}]`)
+	case "/api/v4/groups/effxhq/projects":
+		if query.Get("include_subgroups") != "true" {
+			fmt.Fprintf(w, "[%s]", projectJSON(1, "effxhq", "api"))
+			return
+		}
+
+		// projects of subgroups are paged after those of the group itself
+		if query.Get("page") == "2" {
+			fmt.Fprintf(w, "[%s]", projectJSON(2, "effxhq/platform", "tools"))
+			return
+		}
+		w.Header().Set("X-Next-Page", "2")
+		fmt.Fprintf(w, "[%s]", projectJSON(1, "effxhq", "api"))
+	case "/api/v4/groups/effxhq/platform/projects":
+		fmt.Fprintf(w, "[%s]", projectJSON(2, "effxhq/platform", "tools"))
+	case "/api/v4/user":
+		fmt.Fprint(w, `{"id": 42, "username": "effx"}`)
+	case "/api/v4/users/42/projects":
+		// the group project is reached a second time through the user's membership
+		fmt.Fprintf(w, "[%s, %s]", projectJSON(3, "effx", "dotfiles"), projectJSON(1, "effxhq", "api"))
+	case "/api/v4/users/octocat/projects":
+		fmt.Fprintf(w, "[%s]", projectJSON(4, "octocat", "hello"))
+	default:
+		w.WriteHeader(http.StatusNotFound)
+	}
+}
+
+func discover(t *testing.T, api *fakeGitLab, configure func(cfg *gitlab.Configuration)) []string {
+	server := httptest.NewServer(api)
+	defer server.Close()
+
+	cfg := &gitlab.Configuration{
+		BaseURL:             server.URL,
+		UserName:            "effx",
+		PersonalAccessToken: "token",
+		Groups:              cli.NewStringSlice(),
+		IncludeSubgroups:    true,
+		Users:               cli.NewStringSlice(),
+	}
+	if configure != nil {
+		configure(cfg)
+	}
+
+	ctx := logger.AttachToContext(context.Background(), zap.NewNop())
+
+	integration, err := gitlab.NewIntegration(ctx, cfg, integrations.Filters{})
+	require.NoError(t, err)
+
+	data := make(chan *model.Repository, 100)
+	require.NoError(t, integration.Run(ctx, data))
+	close(data)
+
+	fullNames := make([]string, 0)
+	for repository := range data {
+		fullNames = append(fullNames, repository.FullName)
+	}
+	return fullNames
+}
+
+func TestIntegration_RunSubgroups(t *testing.T) {
+	api := &fakeGitLab{}
+
+	// subgroups are reached through their top level group, rather than listed again
+	fullNames := discover(t, api, nil)
+	require.Equal(t, []string{"effxhq/api", "effxhq/platform/tools"}, fullNames)
+	require.Equal(t, []string{
+		"/api/v4/groups?page=1&per_page=100&top_level_only=true",
+		"/api/v4/groups/effxhq/projects?include_subgroups=true&page=1&per_page=100",
+		"/api/v4/groups/effxhq/projects?include_subgroups=true&page=2&per_page=100",
+	}, api.requests)
+}
+
+func TestIntegration_RunExcludeSubgroups(t *testing.T) {
+	api := &fakeGitLab{}
+
+	fullNames := discover(t, api, func(cfg *gitlab.Configuration) {
+		cfg.IncludeSubgroups = false
+	})
+	require.Equal(t, []string{"effxhq/api"}, fullNames)
+	require.Equal(t, []string{
+		"/api/v4/groups?page=1&per_page=100&top_level_only=true",
+		"/api/v4/groups/effxhq/projects?include_subgroups=false&page=1&per_page=100",
+	}, api.requests)
+}
+
+func TestIntegration_RunUserNamespaces(t *testing.T) {
+	api := &fakeGitLab{}
+
+	fullNames := discover(t, api, func(cfg *gitlab.Configuration) {
+		cfg.Groups = cli.NewStringSlice("effxhq")
+		cfg.IncludeSubgroups = false
+		cfg.IncludeUserProjects = true
+		cfg.Users = cli.NewStringSlice("octocat")
+	})
+
+	// projects reached through more than one namespace are only consumed once
+	require.Equal(t, []string{"effxhq/api", "effx/dotfiles", "octocat/hello"}, fullNames)
+	require.Equal(t, []string{
+		"/api/v4/groups/effxhq/projects?include_subgroups=false&page=1&per_page=100",
+		"/api/v4/user",
+		"/api/v4/users/42/projects?page=1&per_page=100",
+		"/api/v4/users/octocat/projects?page=1&per_page=100",
+	}, api.requests)
+}
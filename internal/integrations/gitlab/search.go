@@ -0,0 +1,81 @@
+package gitlab
+
+import (
+	"context"
+
+	"github.com/effxhq/vcs-connect/internal/model"
+
+	"github.com/xanzy/go-gitlab"
+
+	"go.uber.org/zap"
+)
+
+// searchQueries match effx.yaml files along with prefixed ones such as api.effx.yaml.
+// The wildcard also matches names merely ending in effx.yaml, so results are
+// filtered afterwards, and each extension is searched for separately as filters
+// cannot be combined with OR.
+var searchQueries = []string{"filename:*effx.yaml", "filename:*effx.yml"}
+
+// discoverRepositoriesBySearch lists the projects of a group containing effx.yaml
+// files using blob search, which requires advanced search to be enabled.
+func (i *Integration) discoverRepositoriesBySearch(ctx context.Context, group string) ([]*gitlab.Project, error) {
+	seen := make(map[int]bool)
+	projectIDs := make([]int, 0)
+
+	for _, query := range searchQueries {
+		page := 1
+		for page > 0 {
+			blobs, resp, err := i.client.Search.BlobsByGroup(group, query, &gitlab.SearchOptions{
+				Page:    page,
+				PerPage: 100,
+			}, gitlab.WithContext(ctx))
+			if err != nil {
+				return nil, err
+			}
+
+			for _, blob := range blobs {
+				if !seen[blob.ProjectID] && model.IsEffxYAML(blob.Filename) {
+					seen[blob.ProjectID] = true
+					projectIDs = append(projectIDs, blob.ProjectID)
+				}
+			}
+
+			page = resp.NextPage
+		}
+	}
+
+	// search results only identify projects, so fetch each in full
+	repositories := make([]*gitlab.Project, 0, len(projectIDs))
+	for _, projectID := range projectIDs {
+		project, _, err := i.client.Projects.GetProject(projectID, nil, gitlab.WithContext(ctx))
+		if err != nil {
+			return nil, err
+		}
+
+		// group search always includes subgroups
+		if !i.config.IncludeSubgroups && (project.Namespace == nil || project.Namespace.FullPath != group) {
+			continue
+		}
+
+		repositories = append(repositories, project)
+	}
+
+	return repositories, nil
+}
+
+// discoverCandidates lists the projects of a group through blob search when enabled,
+// falling back to listing every project when the search fails.
+func (i *Integration) discoverCandidates(ctx context.Context, log *zap.Logger, group string) ([]*gitlab.Project, error) {
+	if i.config.CodeSearch {
+		repositories, err := i.discoverRepositoriesBySearch(ctx, group)
+		if err == nil {
+			return repositories, nil
+		}
+
+		log.Warn("failed to search for effx.yaml files, listing every project instead",
+			zap.String("group", group),
+			zap.Error(err))
+	}
+
+	return i.discoverRepositories(ctx, group)
+}
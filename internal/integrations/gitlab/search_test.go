@@ -0,0 +1,112 @@
+package gitlab_test
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/effxhq/vcs-connect/internal/integrations"
+	"github.com/effxhq/vcs-connect/internal/integrations/gitlab"
+	"github.com/effxhq/vcs-connect/internal/logger"
+	"github.com/effxhq/vcs-connect/internal/model"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/urfave/cli/v2"
+
+	"go.uber.org/zap"
+)
+
+// namespaces of the projects served by searchMux, keyed by project id
+var namespaces = map[string]string{
+	"1": "effxhq",
+	"2": "effxhq/platform",
+}
+
+// searchMux serves blob search results for the effxhq group across two pages,
+// including a project in a subgroup and a file which is not an effx.yaml file.
+func searchMux(t *testing.T, queries *[]string) *http.ServeMux {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/api/v4/groups/effxhq/-/search", func(w http.ResponseWriter, r *http.Request) {
+		if scope := r.URL.Query().Get("scope"); scope != "blobs" {
+			t.Errorf("unexpected search scope %q", scope)
+		}
+
+		query := r.URL.Query().Get("search")
+		*queries = append(*queries, query)
+
+		switch {
+		case query == "filename:*effx.yml":
+			fmt.Fprint(w, `[]`)
+		case r.URL.Query().Get("page") == "2":
+			fmt.Fprint(w, `[{"filename": "services/api.effx.yaml", "project_id": 2}]`)
+		default:
+			w.Header().Set("X-Next-Page", "2")
+			fmt.Fprint(w, `[{"filename": "effx.yaml", "project_id": 1}, {"filename": "noteffx.yaml", "project_id": 3}]`)
+		}
+	})
+	mux.HandleFunc("/api/v4/projects/", func(w http.ResponseWriter, r *http.Request) {
+		id := strings.TrimPrefix(r.URL.Path, "/api/v4/projects/")
+		namespace, ok := namespaces[id]
+		if !ok {
+			t.Errorf("project %s should not be fetched", id)
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+
+		fmt.Fprintf(w, `{"id": %s, "path_with_namespace": "%s/project-%s", "http_url_to_repo": "https://gitlab.com/%s/project-%s.git",
+			"default_branch": "main", "namespace": {"full_path": %q}}`, id, namespace, id, namespace, id, namespace)
+	})
+	mux.HandleFunc("/api/v4/groups/effxhq/projects", func(w http.ResponseWriter, r *http.Request) {
+		t.Error("projects should not be listed when the search succeeds")
+	})
+	return mux
+}
+
+// run discovers the projects of the effxhq group through blob search.
+func run(t *testing.T, mux *http.ServeMux, includeSubgroups bool) []string {
+	server := httptest.NewServer(mux)
+	defer server.Close()
+
+	ctx := logger.AttachToContext(context.Background(), zap.NewNop())
+
+	integration, err := gitlab.NewIntegration(ctx, &gitlab.Configuration{
+		BaseURL:             server.URL,
+		UserName:            "effx",
+		PersonalAccessToken: "token",
+		Groups:              cli.NewStringSlice("effxhq"),
+		IncludeSubgroups:    includeSubgroups,
+		Users:               cli.NewStringSlice(),
+		CodeSearch:          true,
+	}, integrations.Filters{})
+	require.NoError(t, err)
+
+	data := make(chan *model.Repository, 100)
+	require.NoError(t, integration.Run(ctx, data))
+	close(data)
+
+	fullNames := make([]string, 0)
+	for repository := range data {
+		fullNames = append(fullNames, repository.FullName)
+	}
+	return fullNames
+}
+
+func TestIntegration_CodeSearch(t *testing.T) {
+	queries := make([]string, 0)
+
+	fullNames := run(t, searchMux(t, &queries), true)
+	require.ElementsMatch(t, []string{"effxhq/project-1", "effxhq/platform/project-2"}, fullNames)
+	require.Equal(t, []string{"filename:*effx.yaml", "filename:*effx.yaml", "filename:*effx.yml"}, queries)
+}
+
+func TestIntegration_CodeSearchExcludeSubgroups(t *testing.T) {
+	queries := make([]string, 0)
+
+	// group search always includes subgroups, so their projects are dropped afterwards
+	fullNames := run(t, searchMux(t, &queries), false)
+	require.Equal(t, []string{"effxhq/project-1"}, fullNames)
+}
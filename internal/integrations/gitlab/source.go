@@ -0,0 +1,71 @@
+package gitlab
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/effxhq/vcs-connect/internal/model"
+
+	"github.com/xanzy/go-gitlab"
+)
+
+// source reads files through the repository api of GitLab.
+type source struct {
+	client  *gitlab.Client
+	project int
+	branch  string
+}
+
+var _ model.Source = &source{}
+
+func (s *source) Tree(ctx context.Context) (*model.Tree, error) {
+	if s.branch == "" {
+		return nil, fmt.Errorf("repository has no default branch")
+	}
+
+	branch, _, err := s.client.Branches.GetBranch(s.project, s.branch, gitlab.WithContext(ctx))
+	if err != nil {
+		return nil, err
+	}
+
+	// list files at the commit, so the tree is consistent even when pushed to while paginating
+	commit, ref := "", s.branch
+	if branch.Commit != nil {
+		commit, ref = branch.Commit.ID, branch.Commit.ID
+	}
+
+	blobs := make(map[string]string)
+
+	page := 1
+	for page > 0 {
+		nodes, resp, err := s.client.Repositories.ListTree(s.project, &gitlab.ListTreeOptions{
+			ListOptions: gitlab.ListOptions{
+				Page:    page,
+				PerPage: 100,
+			},
+			Ref:       gitlab.String(ref),
+			Recursive: gitlab.Bool(true),
+		}, gitlab.WithContext(ctx))
+		if err != nil {
+			return nil, err
+		}
+
+		for _, node := range nodes {
+			if node.Type == "blob" {
+				blobs[node.Path] = node.ID
+			}
+		}
+
+		page = resp.NextPage
+	}
+
+	return &model.Tree{
+		Commit: commit,
+		Blobs:  blobs,
+	}, nil
+}
+
+func (s *source) ReadBlob(ctx context.Context, id string) ([]byte, error) {
+	body, _, err := s.client.Repositories.RawBlobContent(s.project, id, gitlab.WithContext(ctx))
+	return body, err
+}
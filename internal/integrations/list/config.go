@@ -0,0 +1,69 @@
+package list
+
+import (
+	"fmt"
+
+	"github.com/urfave/cli/v2"
+)
+
+// Configuration encapsulates information needed for reading a static list of repositories
+type Configuration struct {
+	File     string
+	Format   string
+	UserName string
+	Password string
+}
+
+// Validate ensures the configuration provided contains the required information.
+func (c *Configuration) Validate() error {
+	if c.File == "" {
+		return fmt.Errorf("a file must be provided")
+	}
+
+	switch c.Format {
+	case "", FormatYAML, FormatJSON, FormatText:
+		return nil
+	default:
+		return fmt.Errorf("unsupported format %s", c.Format)
+	}
+}
+
+// DefaultConfigWithFlags returns configuration and flags specific to static repository lists
+func DefaultConfigWithFlags() (*Configuration, []cli.Flag) {
+	cfg := &Configuration{
+		File: "-",
+	}
+
+	flags := []cli.Flag{
+		&cli.StringFlag{
+			Name:        "list-file",
+			Usage:       "file containing the repositories to index, or - to read from stdin",
+			Destination: &(cfg.File),
+			Value:       cfg.File,
+			EnvVars:     []string{"LIST_FILE"},
+		},
+		&cli.StringFlag{
+			Name:        "list-format",
+			Usage:       "format of the list (yaml, json or text), detected from the file extension when omitted",
+			Destination: &(cfg.Format),
+			Value:       cfg.Format,
+			EnvVars:     []string{"LIST_FORMAT"},
+		},
+		&cli.StringFlag{
+			Name:        "list-username",
+			Usage:       "optional username used to clone repositories",
+			Destination: &(cfg.UserName),
+			Value:       cfg.UserName,
+			EnvVars:     []string{"LIST_USERNAME"},
+		},
+		&cli.StringFlag{
+			Name:        "list-password",
+			Usage:       "optional password or token used to clone repositories",
+			Destination: &(cfg.Password),
+			Value:       cfg.Password,
+			EnvVars:     []string{"LIST_PASSWORD"},
+		},
+	}
+
+	return cfg, flags
+}
@@ -0,0 +1,82 @@
+package list
+
+import (
+	"context"
+	"io"
+	"os"
+
+	"github.com/effxhq/vcs-connect/internal/logger"
+	"github.com/effxhq/vcs-connect/internal/model"
+
+	"github.com/pkg/errors"
+
+	"go.uber.org/zap"
+)
+
+// NewIntegration returns the Integration responsible for reading a static list of repositories.
+// Before construction, the Configuration is validated to ensure it contains the
+// proper information.
+func NewIntegration(ctx context.Context, config *Configuration) (*Integration, error) {
+	if err := config.Validate(); err != nil {
+		return nil, err
+	}
+
+	return &Integration{
+		config: config,
+		stdin:  os.Stdin,
+	}, nil
+}
+
+// Integration encapsulates the logic for integrating data from a static list.
+type Integration struct {
+	config *Configuration
+	stdin  io.Reader
+}
+
+func (i *Integration) discoverRepositories() ([]*model.Repository, error) {
+	format := i.config.Format
+
+	if i.config.File == "-" {
+		if format == "" {
+			format = FormatText
+		}
+		return Parse(i.stdin, format)
+	}
+
+	if format == "" {
+		format = DetectFormat(i.config.File)
+	}
+
+	file, err := os.Open(i.config.File)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	return Parse(file, format)
+}
+
+// Run feeds the data channel with the repositories found in the list
+func (i *Integration) Run(ctx context.Context, data chan *model.Repository) error {
+	log := logger.MustGetFromContext(ctx)
+
+	repositories, err := i.discoverRepositories()
+	if err != nil {
+		return errors.Wrap(err, "failed to read repository list")
+	}
+
+	// push to consumers or stop if cancelled
+	for _, repository := range repositories {
+		log.Info("processing repository",
+			zap.String("repository", repository.CloneURL))
+
+		select {
+		case <-ctx.Done():
+			return nil
+		case data <- repository:
+			continue
+		}
+	}
+
+	return nil
+}
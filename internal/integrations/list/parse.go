@@ -0,0 +1,107 @@
+package list
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io"
+	"path/filepath"
+	"strings"
+
+	"github.com/effxhq/vcs-connect/internal/model"
+
+	"gopkg.in/yaml.v3"
+)
+
+// Supported list formats.
+const (
+	FormatYAML = "yaml"
+	FormatJSON = "json"
+	FormatText = "text"
+)
+
+// entry is a single repository in a YAML or JSON list.
+type entry struct {
+	CloneURL    string            `json:"cloneURL" yaml:"cloneURL"`
+	SSHURL      string            `json:"sshURL" yaml:"sshURL"`
+	Tags        map[string]string `json:"tags" yaml:"tags"`
+	Annotations map[string]string `json:"annotations" yaml:"annotations"`
+}
+
+// DetectFormat infers the format of the list from the extension of the file.
+// Anything that is not YAML or JSON is treated as newline delimited text.
+func DetectFormat(file string) string {
+	switch strings.ToLower(filepath.Ext(file)) {
+	case ".yaml", ".yml":
+		return FormatYAML
+	case ".json":
+		return FormatJSON
+	default:
+		return FormatText
+	}
+}
+
+// Parse reads repositories from the provided reader. YAML and JSON lists contain
+// objects with a cloneURL and optional tags and annotations. Text lists contain one
+// clone url per line, where blank lines and lines starting with # are ignored.
+func Parse(reader io.Reader, format string) ([]*model.Repository, error) {
+	entries := make([]*entry, 0)
+
+	switch format {
+	case FormatYAML:
+		if err := yaml.NewDecoder(reader).Decode(&entries); err != nil && err != io.EOF {
+			return nil, err
+		}
+
+	case FormatJSON:
+		if err := json.NewDecoder(reader).Decode(&entries); err != nil {
+			return nil, err
+		}
+
+	case FormatText:
+		scanner := bufio.NewScanner(reader)
+		for scanner.Scan() {
+			line := strings.TrimSpace(scanner.Text())
+			if line == "" || strings.HasPrefix(line, "#") {
+				continue
+			}
+			entries = append(entries, &entry{CloneURL: line})
+		}
+
+		if err := scanner.Err(); err != nil {
+			return nil, err
+		}
+
+	default:
+		return nil, fmt.Errorf("unsupported format %s", format)
+	}
+
+	repositories := make([]*model.Repository, len(entries))
+	for i, e := range entries {
+		// null items decode to nil entries
+		if e == nil {
+			return nil, fmt.Errorf("entry %d is empty", i)
+		} else if e.CloneURL == "" {
+			return nil, fmt.Errorf("entry %d is missing a cloneURL", i)
+		}
+
+		repository := &model.Repository{
+			CloneURL:    e.CloneURL,
+			SSHURL:      e.SSHURL,
+			Tags:        map[string]string{},
+			Annotations: map[string]string{},
+		}
+
+		for k, v := range e.Tags {
+			repository.Tags[k] = v
+		}
+
+		for k, v := range e.Annotations {
+			repository.Annotations[k] = v
+		}
+
+		repositories[i] = repository
+	}
+
+	return repositories, nil
+}
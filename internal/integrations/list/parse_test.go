@@ -0,0 +1,78 @@
+package list_test
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/effxhq/vcs-connect/internal/integrations/list"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestDetectFormat(t *testing.T) {
+	require.Equal(t, list.FormatYAML, list.DetectFormat("repos.yaml"))
+	require.Equal(t, list.FormatYAML, list.DetectFormat("repos.YML"))
+	require.Equal(t, list.FormatJSON, list.DetectFormat("repos.json"))
+	require.Equal(t, list.FormatText, list.DetectFormat("repos.txt"))
+	require.Equal(t, list.FormatText, list.DetectFormat("repos"))
+}
+
+func TestParse_YAML(t *testing.T) {
+	input := `
+- cloneURL: https://github.com/effxhq/vcs-connect.git
+  tags:
+    team: platform
+  annotations:
+    effx.io/owner: platform
+- cloneURL: https://gitlab.com/effxhq/other.git
+`
+
+	repositories, err := list.Parse(strings.NewReader(input), list.FormatYAML)
+	require.NoError(t, err)
+	require.Len(t, repositories, 2)
+
+	require.Equal(t, "https://github.com/effxhq/vcs-connect.git", repositories[0].CloneURL)
+	require.Equal(t, "platform", repositories[0].Tags["team"])
+	require.Equal(t, "platform", repositories[0].Annotations["effx.io/owner"])
+
+	require.Equal(t, "https://gitlab.com/effxhq/other.git", repositories[1].CloneURL)
+	require.NotNil(t, repositories[1].Tags)
+	require.NotNil(t, repositories[1].Annotations)
+}
+
+func TestParse_JSON(t *testing.T) {
+	input := `[{"cloneURL": "https://github.com/effxhq/vcs-connect.git", "tags": {"team": "platform"}}]`
+
+	repositories, err := list.Parse(strings.NewReader(input), list.FormatJSON)
+	require.NoError(t, err)
+	require.Len(t, repositories, 1)
+	require.Equal(t, "platform", repositories[0].Tags["team"])
+}
+
+func TestParse_Text(t *testing.T) {
+	input := `
+# platform repositories
+https://github.com/effxhq/vcs-connect.git
+
+  https://gitlab.com/effxhq/other.git
+`
+
+	repositories, err := list.Parse(strings.NewReader(input), list.FormatText)
+	require.NoError(t, err)
+	require.Len(t, repositories, 2)
+	require.Equal(t, "https://github.com/effxhq/vcs-connect.git", repositories[0].CloneURL)
+	require.Equal(t, "https://gitlab.com/effxhq/other.git", repositories[1].CloneURL)
+}
+
+func TestParse_MissingCloneURL(t *testing.T) {
+	_, err := list.Parse(strings.NewReader(`[{"tags": {"team": "platform"}}]`), list.FormatJSON)
+	require.Error(t, err)
+}
+
+func TestParse_EmptyEntry(t *testing.T) {
+	_, err := list.Parse(strings.NewReader("- cloneURL: https://github.com/effxhq/vcs-connect.git\n-\n"), list.FormatYAML)
+	require.EqualError(t, err, "entry 1 is empty")
+
+	_, err = list.Parse(strings.NewReader(`[null]`), list.FormatJSON)
+	require.EqualError(t, err, "entry 0 is empty")
+}
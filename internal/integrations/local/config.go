@@ -0,0 +1,40 @@
+package local
+
+import (
+	"fmt"
+
+	"github.com/urfave/cli/v2"
+)
+
+// Configuration encapsulates information needed for indexing directories that
+// are already present on the local filesystem
+type Configuration struct {
+	Directories *cli.StringSlice
+}
+
+// Validate ensures the configuration provided contains the required information.
+func (c *Configuration) Validate() error {
+	if len(c.Directories.Value()) == 0 {
+		return fmt.Errorf("at least one directory must be provided")
+	}
+	return nil
+}
+
+// DefaultConfigWithFlags returns configuration and flags specific to local directories
+func DefaultConfigWithFlags() (*Configuration, []cli.Flag) {
+	cfg := &Configuration{
+		Directories: cli.NewStringSlice(),
+	}
+
+	flags := []cli.Flag{
+		&cli.StringSliceFlag{
+			Name:        "local-directories",
+			Usage:       "directories (or glob patterns matching directories) to index as repositories",
+			Destination: cfg.Directories,
+			Value:       cfg.Directories,
+			EnvVars:     []string{"LOCAL_DIRECTORIES"},
+		},
+	}
+
+	return cfg, flags
+}
@@ -0,0 +1,140 @@
+package local
+
+import (
+	"context"
+	"net/url"
+	"os"
+	"path/filepath"
+
+	"github.com/effxhq/vcs-connect/internal/logger"
+	"github.com/effxhq/vcs-connect/internal/model"
+
+	"github.com/pkg/errors"
+
+	"go.uber.org/zap"
+
+	"gopkg.in/src-d/go-git.v4"
+)
+
+// NewIntegration returns the Integration responsible for indexing local directories.
+// Before construction, the Configuration is validated to ensure it contains the
+// proper information.
+func NewIntegration(ctx context.Context, config *Configuration) (*Integration, error) {
+	if err := config.Validate(); err != nil {
+		return nil, err
+	}
+
+	return &Integration{
+		config: config,
+	}, nil
+}
+
+// Integration encapsulates the logic for integrating data from the local filesystem.
+type Integration struct {
+	config *Configuration
+}
+
+// checkout describes the git repository containing dir. The root of its worktree
+// is returned along with the url of its origin remote, with any credentials
+// removed. The root is dir itself when dir is not part of a git repository, and
+// the remote url is empty when no origin remote is configured.
+func checkout(dir string) (root, remoteURL string) {
+	repo, err := git.PlainOpenWithOptions(dir, &git.PlainOpenOptions{DetectDotGit: true})
+	if err != nil {
+		return dir, ""
+	}
+
+	root = dir
+	if worktree, err := repo.Worktree(); err == nil {
+		root = worktree.Filesystem.Root()
+	}
+
+	remote, err := repo.Remote(git.DefaultRemoteName)
+	if err != nil || len(remote.Config().URLs) == 0 {
+		return root, ""
+	}
+
+	remoteURL = remote.Config().URLs[0]
+	if u, err := url.Parse(remoteURL); err == nil && u.User != nil {
+		u.User = nil
+		remoteURL = u.String()
+	}
+
+	return root, remoteURL
+}
+
+// discoverRepositories returns the directories to index. Directories inside a git
+// repository are replaced by the root of its worktree, so each checkout is
+// indexed once and its effx.yaml files are identified relative to the root, just
+// like for cloned repositories.
+func (i *Integration) discoverRepositories() ([]*model.Repository, error) {
+	repositories := make([]*model.Repository, 0)
+	seen := make(map[string]bool)
+
+	for _, pattern := range i.config.Directories.Value() {
+		matches, err := filepath.Glob(pattern)
+		if err != nil {
+			return nil, errors.Wrapf(err, "invalid directory pattern %s", pattern)
+		}
+
+		for _, match := range matches {
+			dir, err := filepath.Abs(match)
+			if err != nil {
+				return nil, err
+			}
+
+			info, err := os.Stat(dir)
+			if err != nil {
+				return nil, err
+			} else if !info.IsDir() {
+				continue
+			}
+
+			root, cloneURL := checkout(dir)
+			if seen[root] {
+				continue
+			}
+			seen[root] = true
+
+			// fall back to the directory itself when no remote is configured
+			if cloneURL == "" {
+				cloneURL = root
+			}
+
+			repositories = append(repositories, &model.Repository{
+				CloneURL:    cloneURL,
+				WorkDir:     root,
+				Tags:        map[string]string{},
+				Annotations: map[string]string{},
+			})
+		}
+	}
+
+	return repositories, nil
+}
+
+// Run feeds the data channel with the configured directories
+func (i *Integration) Run(ctx context.Context, data chan *model.Repository) error {
+	log := logger.MustGetFromContext(ctx)
+
+	repositories, err := i.discoverRepositories()
+	if err != nil {
+		return errors.Wrap(err, "failed to discover local directories")
+	}
+
+	for _, repository := range repositories {
+		log.Info("processing repository",
+			zap.String("repository", repository.CloneURL),
+			zap.String("directory", repository.WorkDir))
+
+		// push to consumers or stop if cancelled
+		select {
+		case <-ctx.Done():
+			return nil
+		case data <- repository:
+			continue
+		}
+	}
+
+	return nil
+}
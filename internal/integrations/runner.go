@@ -11,3 +11,22 @@ import (
 type Runner interface {
 	Run(ctx context.Context, data chan *model.Repository) error
 }
+
+// Filter decides which repositories discovered by an integration are handed to consumers.
+type Filter interface {
+	// Reason returns why the repository is excluded, or an empty string when it should be consumed.
+	Reason(repository *model.Repository) string
+}
+
+// Filters combines multiple filters, excluding a repository when any of them excludes it.
+type Filters []Filter
+
+// Reason returns the first reason a repository is excluded, or an empty string when it should be consumed.
+func (f Filters) Reason(repository *model.Repository) string {
+	for _, filter := range f {
+		if reason := filter.Reason(repository); reason != "" {
+			return reason
+		}
+	}
+	return ""
+}
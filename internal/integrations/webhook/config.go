@@ -0,0 +1,84 @@
+package webhook
+
+import (
+	"fmt"
+
+	"github.com/urfave/cli/v2"
+)
+
+// Configuration encapsulates information needed for receiving push webhooks
+type Configuration struct {
+	Address      string
+	GitHubSecret string
+	GitLabToken  string
+	QueueSize    int
+	UserName     string
+	Password     string
+}
+
+// Validate ensures the configuration provided contains the required information.
+func (c *Configuration) Validate() error {
+	if c.Address == "" {
+		return fmt.Errorf("an address must be provided")
+	} else if c.GitHubSecret == "" && c.GitLabToken == "" {
+		return fmt.Errorf("a GitHub secret or GitLab token must be provided")
+	} else if c.QueueSize <= 0 {
+		return fmt.Errorf("the queue size must be positive")
+	}
+	return nil
+}
+
+// DefaultConfigWithFlags returns configuration and flags specific to webhooks
+func DefaultConfigWithFlags() (*Configuration, []cli.Flag) {
+	cfg := &Configuration{
+		Address:   ":8080",
+		QueueSize: 100,
+	}
+
+	flags := []cli.Flag{
+		&cli.StringFlag{
+			Name:        "webhook-address",
+			Usage:       "address the webhook receiver listens on",
+			Destination: &(cfg.Address),
+			Value:       cfg.Address,
+			EnvVars:     []string{"WEBHOOK_ADDRESS"},
+		},
+		&cli.StringFlag{
+			Name:        "webhook-github-secret",
+			Usage:       "secret used to verify the signature of GitHub webhooks, enables /webhooks/github",
+			Destination: &(cfg.GitHubSecret),
+			Value:       cfg.GitHubSecret,
+			EnvVars:     []string{"WEBHOOK_GITHUB_SECRET"},
+		},
+		&cli.StringFlag{
+			Name:        "webhook-gitlab-token",
+			Usage:       "secret token expected on GitLab webhooks, enables /webhooks/gitlab",
+			Destination: &(cfg.GitLabToken),
+			Value:       cfg.GitLabToken,
+			EnvVars:     []string{"WEBHOOK_GITLAB_TOKEN"},
+		},
+		&cli.IntFlag{
+			Name:        "webhook-queue-size",
+			Usage:       "the number of pushes waiting to be indexed before webhooks are rejected",
+			Destination: &(cfg.QueueSize),
+			Value:       cfg.QueueSize,
+			EnvVars:     []string{"WEBHOOK_QUEUE_SIZE"},
+		},
+		&cli.StringFlag{
+			Name:        "webhook-username",
+			Usage:       "optional username used to clone repositories",
+			Destination: &(cfg.UserName),
+			Value:       cfg.UserName,
+			EnvVars:     []string{"WEBHOOK_USERNAME"},
+		},
+		&cli.StringFlag{
+			Name:        "webhook-password",
+			Usage:       "optional password or token used to clone repositories",
+			Destination: &(cfg.Password),
+			Value:       cfg.Password,
+			EnvVars:     []string{"WEBHOOK_PASSWORD"},
+		},
+	}
+
+	return cfg, flags
+}
@@ -0,0 +1,193 @@
+package webhook
+
+import (
+	"strings"
+
+	"github.com/effxhq/vcs-connect/internal/filter"
+	"github.com/effxhq/vcs-connect/internal/model"
+)
+
+const (
+	branchPrefix = "refs/heads/"
+	zeroCommit   = "0000000000000000000000000000000000000000"
+
+	// GitHub lists at most this many commits in a push event, without reporting
+	// how many were pushed in total
+	gitHubCommitsLimit = 2048
+)
+
+type commit struct {
+	Added    []string `json:"added"`
+	Modified []string `json:"modified"`
+	Removed  []string `json:"removed"`
+}
+
+// touchesEffxYAML reports whether any of the commits changed an effx.yaml file.
+func touchesEffxYAML(commits []commit) bool {
+	for _, c := range commits {
+		for _, files := range [][]string{c.Added, c.Modified, c.Removed} {
+			for _, file := range files {
+				if model.IsEffxYAML(file) {
+					return true
+				}
+			}
+		}
+	}
+	return false
+}
+
+// push is the information common to push events from every host.
+type push struct {
+	ref           string
+	after         string
+	defaultBranch string
+	commits       []commit
+	// truncated is set when the host omitted some of the pushed commits
+	truncated bool
+}
+
+// reason returns why the push is ignored, or an empty string when the repository should be indexed.
+func (p *push) reason() string {
+	switch {
+	case p.after == zeroCommit:
+		return "branch deleted"
+	case !strings.HasPrefix(p.ref, branchPrefix):
+		return "not a branch"
+	case p.defaultBranch != "" && strings.TrimPrefix(p.ref, branchPrefix) != p.defaultBranch:
+		return "not the default branch"
+	case !p.truncated && !touchesEffxYAML(p.commits):
+		return "no effx.yaml files changed"
+	}
+	return ""
+}
+
+// GitHubReportedProperties are the properties of repositories reported by GitHub
+// push webhooks.
+const GitHubReportedProperties = filter.Archived | filter.Forks | filter.Templates | filter.Visibility | filter.Topics
+
+type gitHubPush struct {
+	Ref        string   `json:"ref"`
+	After      string   `json:"after"`
+	Commits    []commit `json:"commits"`
+	Repository struct {
+		FullName      string   `json:"full_name"`
+		CloneURL      string   `json:"clone_url"`
+		SSHURL        string   `json:"ssh_url"`
+		HTMLURL       string   `json:"html_url"`
+		DefaultBranch string   `json:"default_branch"`
+		Private       bool     `json:"private"`
+		Visibility    string   `json:"visibility"`
+		Topics        []string `json:"topics"`
+		Archived      bool     `json:"archived"`
+		Fork          bool     `json:"fork"`
+		IsTemplate    bool     `json:"is_template"`
+		Owner         struct {
+			Login string `json:"login"`
+			Name  string `json:"name"`
+		} `json:"owner"`
+	} `json:"repository"`
+}
+
+func (e *gitHubPush) push() *push {
+	return &push{
+		ref:           e.Ref,
+		after:         e.After,
+		defaultBranch: e.Repository.DefaultBranch,
+		commits:       e.Commits,
+		truncated:     len(e.Commits) >= gitHubCommitsLimit,
+	}
+}
+
+func (e *gitHubPush) toRepository() *model.Repository {
+	repo := e.Repository
+
+	visibility := repo.Visibility
+	if visibility == "" {
+		visibility = "public"
+		if repo.Private {
+			visibility = "private"
+		}
+	}
+
+	owner := repo.Owner.Login
+	if owner == "" {
+		owner = repo.Owner.Name
+	}
+
+	return &model.Repository{
+		CloneURL:      repo.CloneURL,
+		SSHURL:        repo.SSHURL,
+		Tags:          map[string]string{},
+		Annotations:   map[string]string{},
+		FullName:      repo.FullName,
+		Owner:         owner,
+		DefaultBranch: repo.DefaultBranch,
+		WebURL:        repo.HTMLURL,
+		Visibility:    visibility,
+		Topics:        repo.Topics,
+		Archived:      repo.Archived,
+		Fork:          repo.Fork,
+		Template:      repo.IsTemplate,
+	}
+}
+
+// GitLabReportedProperties are the properties of projects reported by GitLab
+// push webhooks.
+const GitLabReportedProperties = filter.Visibility
+
+type gitLabPush struct {
+	ObjectKind        string   `json:"object_kind"`
+	Ref               string   `json:"ref"`
+	After             string   `json:"after"`
+	Commits           []commit `json:"commits"`
+	TotalCommitsCount int      `json:"total_commits_count"`
+	Project           struct {
+		PathWithNamespace string `json:"path_with_namespace"`
+		Namespace         string `json:"namespace"`
+		GitHTTPURL        string `json:"git_http_url"`
+		GitSSHURL         string `json:"git_ssh_url"`
+		WebURL            string `json:"web_url"`
+		DefaultBranch     string `json:"default_branch"`
+		VisibilityLevel   int    `json:"visibility_level"`
+	} `json:"project"`
+}
+
+func (e *gitLabPush) push() *push {
+	return &push{
+		ref:           e.Ref,
+		after:         e.After,
+		defaultBranch: e.Project.DefaultBranch,
+		commits:       e.Commits,
+		truncated:     e.TotalCommitsCount > len(e.Commits),
+	}
+}
+
+func (e *gitLabPush) toRepository() *model.Repository {
+	project := e.Project
+
+	// https://docs.gitlab.com/ee/api/projects.html#project-visibility-level
+	visibility := "private"
+	switch project.VisibilityLevel {
+	case 10:
+		visibility = "internal"
+	case 20:
+		visibility = "public"
+	}
+
+	owner := project.Namespace
+	if idx := strings.LastIndex(project.PathWithNamespace, "/"); idx > 0 {
+		owner = project.PathWithNamespace[:idx]
+	}
+
+	return &model.Repository{
+		CloneURL:      project.GitHTTPURL,
+		SSHURL:        project.GitSSHURL,
+		Tags:          map[string]string{},
+		Annotations:   map[string]string{},
+		FullName:      project.PathWithNamespace,
+		Owner:         owner,
+		DefaultBranch: project.DefaultBranch,
+		WebURL:        project.WebURL,
+		Visibility:    visibility,
+	}
+}
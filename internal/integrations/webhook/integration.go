@@ -0,0 +1,238 @@
+package webhook
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/hex"
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/effxhq/vcs-connect/internal/integrations"
+	"github.com/effxhq/vcs-connect/internal/logger"
+	"github.com/effxhq/vcs-connect/internal/model"
+
+	"github.com/pkg/errors"
+
+	"go.uber.org/zap"
+)
+
+const (
+	// maxBodySize bounds the size of webhook payloads that are read into memory.
+	maxBodySize = 25 << 20
+
+	shutdownTimeout = 10 * time.Second
+)
+
+// NewIntegration returns the Integration responsible for receiving push webhooks.
+// Before construction, the Configuration is validated to ensure it contains the
+// proper information.
+func NewIntegration(ctx context.Context, config *Configuration, filter integrations.Filter) (*Integration, error) {
+	if err := config.Validate(); err != nil {
+		return nil, err
+	}
+
+	return &Integration{
+		config: config,
+		filter: filter,
+	}, nil
+}
+
+// Integration encapsulates the logic for receiving repositories from push webhooks.
+type Integration struct {
+	config *Configuration
+	filter integrations.Filter
+}
+
+// verifyGitHubSignature checks the X-Hub-Signature-256 header against the HMAC of the body.
+func verifyGitHubSignature(secret string, body []byte, signature string) bool {
+	if !strings.HasPrefix(signature, "sha256=") {
+		return false
+	}
+
+	actual, err := hex.DecodeString(strings.TrimPrefix(signature, "sha256="))
+	if err != nil {
+		return false
+	}
+
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write(body)
+	return hmac.Equal(actual, mac.Sum(nil))
+}
+
+// verifyGitLabToken checks the X-Gitlab-Token header against the configured token.
+func verifyGitLabToken(token, actual string) bool {
+	return subtle.ConstantTimeCompare([]byte(token), []byte(actual)) == 1
+}
+
+// enqueue hands the repository to the queue when the push is relevant and writes the response.
+func (i *Integration) enqueue(
+	log *zap.Logger,
+	w http.ResponseWriter,
+	queue chan<- *model.Repository,
+	event *push,
+	repository *model.Repository,
+) {
+	reason := event.reason()
+	if reason == "" {
+		reason = i.filter.Reason(repository)
+	}
+
+	if reason != "" {
+		log.Info("skipping repository",
+			zap.String("repository", repository.CloneURL),
+			zap.String("reason", reason))
+		w.WriteHeader(http.StatusOK)
+		return
+	}
+
+	select {
+	case queue <- repository:
+		log.Info("processing repository",
+			zap.String("repository", repository.CloneURL))
+		w.WriteHeader(http.StatusAccepted)
+	default:
+		log.Warn("queue is full, rejecting webhook",
+			zap.String("repository", repository.CloneURL))
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}
+}
+
+func readBody(w http.ResponseWriter, r *http.Request) ([]byte, bool) {
+	if r.Method != http.MethodPost {
+		w.WriteHeader(http.StatusMethodNotAllowed)
+		return nil, false
+	}
+
+	body, err := ioutil.ReadAll(http.MaxBytesReader(w, r.Body, maxBodySize))
+	if err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		return nil, false
+	}
+	return body, true
+}
+
+func (i *Integration) handleGitHub(log *zap.Logger, queue chan<- *model.Repository) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		body, ok := readBody(w, r)
+		if !ok {
+			return
+		}
+
+		if !verifyGitHubSignature(i.config.GitHubSecret, body, r.Header.Get("X-Hub-Signature-256")) {
+			log.Warn("rejecting GitHub webhook with invalid signature")
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+
+		// ping and other events are acknowledged, but ignored
+		if r.Header.Get("X-GitHub-Event") != "push" {
+			w.WriteHeader(http.StatusOK)
+			return
+		}
+
+		event := &gitHubPush{}
+		if err := json.Unmarshal(body, event); err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+
+		i.enqueue(log, w, queue, event.push(), event.toRepository())
+	}
+}
+
+func (i *Integration) handleGitLab(log *zap.Logger, queue chan<- *model.Repository) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		body, ok := readBody(w, r)
+		if !ok {
+			return
+		}
+
+		if !verifyGitLabToken(i.config.GitLabToken, r.Header.Get("X-Gitlab-Token")) {
+			log.Warn("rejecting GitLab webhook with invalid token")
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+
+		event := &gitLabPush{}
+		if err := json.Unmarshal(body, event); err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+
+		// other events are acknowledged, but ignored
+		if event.ObjectKind != "push" {
+			w.WriteHeader(http.StatusOK)
+			return
+		}
+
+		i.enqueue(log, w, queue, event.push(), event.toRepository())
+	}
+}
+
+// Handler returns the http.Handler serving the webhook endpoints. Repositories
+// from relevant pushes are written to the queue without blocking.
+func (i *Integration) Handler(log *zap.Logger, queue chan<- *model.Repository) http.Handler {
+	mux := http.NewServeMux()
+
+	mux.HandleFunc("/healthz", func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	})
+
+	if i.config.GitHubSecret != "" {
+		mux.Handle("/webhooks/github", i.handleGitHub(log, queue))
+	}
+
+	if i.config.GitLabToken != "" {
+		mux.Handle("/webhooks/gitlab", i.handleGitLab(log, queue))
+	}
+
+	return mux
+}
+
+// Run serves webhooks and feeds the data channel with pushed repositories until the program is shutdown.
+func (i *Integration) Run(ctx context.Context, data chan *model.Repository) error {
+	log := logger.MustGetFromContext(ctx)
+
+	queue := make(chan *model.Repository, i.config.QueueSize)
+
+	server := &http.Server{
+		Addr:    i.config.Address,
+		Handler: i.Handler(log, queue),
+	}
+
+	errs := make(chan error, 1)
+	go func() {
+		log.Info("listening for webhooks", zap.String("address", i.config.Address))
+		errs <- server.ListenAndServe()
+	}()
+
+	defer func() {
+		shutdownCtx, cancel := context.WithTimeout(context.Background(), shutdownTimeout)
+		defer cancel()
+
+		if err := server.Shutdown(shutdownCtx); err != nil {
+			log.Error("failed to shutdown webhook server", zap.Error(err))
+		}
+	}()
+
+	// push to consumers until cancelled
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case err := <-errs:
+			return errors.Wrap(err, "failed to serve webhooks")
+		case repository := <-queue:
+			select {
+			case <-ctx.Done():
+				return nil
+			case data <- repository:
+			}
+		}
+	}
+}
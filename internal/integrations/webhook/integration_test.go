@@ -0,0 +1,146 @@
+package webhook_test
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/effxhq/vcs-connect/internal/integrations"
+	"github.com/effxhq/vcs-connect/internal/integrations/webhook"
+	"github.com/effxhq/vcs-connect/internal/model"
+
+	"github.com/stretchr/testify/require"
+
+	"go.uber.org/zap"
+)
+
+const gitHubPush = `{
+  "ref": "refs/heads/main",
+  "after": "6dcb09b5b57875f334f61aebed695e2e4193db5e",
+  "commits": [{"added": [], "modified": ["services/api/effx.yaml"], "removed": []}],
+  "repository": {
+    "full_name": "effxhq/vcs-connect",
+    "clone_url": "https://github.com/effxhq/vcs-connect.git",
+    "ssh_url": "git@github.com:effxhq/vcs-connect.git",
+    "default_branch": "main",
+    "private": true,
+    "owner": {"login": "effxhq"}
+  }
+}`
+
+const gitLabPush = `{
+  "object_kind": "push",
+  "ref": "refs/heads/feature",
+  "after": "da1560886d4f094c3e6c9ef40349f7d38b5d27d7",
+  "commits": [{"added": ["effx.yaml"], "modified": [], "removed": []}],
+  "total_commits_count": 1,
+  "project": {
+    "path_with_namespace": "effxhq/platform/vcs-connect",
+    "git_http_url": "https://gitlab.com/effxhq/platform/vcs-connect.git",
+    "default_branch": "main",
+    "visibility_level": 10
+  }
+}`
+
+func sign(secret, body string) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(body))
+	return "sha256=" + hex.EncodeToString(mac.Sum(nil))
+}
+
+func setup(t *testing.T) (http.Handler, chan *model.Repository) {
+	integration, err := webhook.NewIntegration(context.Background(), &webhook.Configuration{
+		Address:      ":8080",
+		GitHubSecret: "github-secret",
+		GitLabToken:  "gitlab-token",
+		QueueSize:    1,
+	}, integrations.Filters{})
+	require.NoError(t, err)
+
+	queue := make(chan *model.Repository, 1)
+	return integration.Handler(zap.NewNop(), queue), queue
+}
+
+func TestHandler_GitHub(t *testing.T) {
+	handler, queue := setup(t)
+
+	post := func(signature string) int {
+		req := httptest.NewRequest(http.MethodPost, "/webhooks/github", strings.NewReader(gitHubPush))
+		req.Header.Set("X-GitHub-Event", "push")
+		req.Header.Set("X-Hub-Signature-256", signature)
+
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, req)
+		return rec.Code
+	}
+
+	require.Equal(t, http.StatusUnauthorized, post(sign("wrong-secret", gitHubPush)))
+	require.Equal(t, http.StatusUnauthorized, post(""))
+	require.Len(t, queue, 0)
+
+	require.Equal(t, http.StatusAccepted, post(sign("github-secret", gitHubPush)))
+	require.Len(t, queue, 1)
+
+	repository := <-queue
+	require.Equal(t, "https://github.com/effxhq/vcs-connect.git", repository.CloneURL)
+	require.Equal(t, "git@github.com:effxhq/vcs-connect.git", repository.SSHURL)
+	require.Equal(t, "effxhq", repository.Owner)
+	require.Equal(t, "private", repository.Visibility)
+}
+
+func TestHandler_GitHubTruncated(t *testing.T) {
+	handler, queue := setup(t)
+
+	// GitHub omits commits beyond its limit, so large pushes may have changed
+	// effx.yaml files without listing them
+	commits := strings.TrimSuffix(strings.Repeat(`{"added": [], "modified": ["README.md"], "removed": []},`, 2048), ",")
+	body := strings.Replace(gitHubPush, `{"added": [], "modified": ["services/api/effx.yaml"], "removed": []}`, commits, 1)
+
+	req := httptest.NewRequest(http.MethodPost, "/webhooks/github", strings.NewReader(body))
+	req.Header.Set("X-GitHub-Event", "push")
+	req.Header.Set("X-Hub-Signature-256", sign("github-secret", body))
+
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, req)
+	require.Equal(t, http.StatusAccepted, rec.Code)
+	require.Len(t, queue, 1)
+}
+
+func TestHandler_GitLab(t *testing.T) {
+	handler, queue := setup(t)
+
+	post := func(token, body string) int {
+		req := httptest.NewRequest(http.MethodPost, "/webhooks/gitlab", strings.NewReader(body))
+		req.Header.Set("X-Gitlab-Event", "Push Hook")
+		req.Header.Set("X-Gitlab-Token", token)
+
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, req)
+		return rec.Code
+	}
+
+	require.Equal(t, http.StatusUnauthorized, post("wrong-token", gitLabPush))
+
+	// pushes to other branches are ignored
+	require.Equal(t, http.StatusOK, post("gitlab-token", gitLabPush))
+	require.Len(t, queue, 0)
+
+	defaultBranch := strings.Replace(gitLabPush, "refs/heads/feature", "refs/heads/main", 1)
+	require.Equal(t, http.StatusAccepted, post("gitlab-token", defaultBranch))
+	require.Len(t, queue, 1)
+
+	repository := <-queue
+	require.Equal(t, "https://gitlab.com/effxhq/platform/vcs-connect.git", repository.CloneURL)
+	require.Equal(t, "effxhq/platform", repository.Owner)
+	require.Equal(t, "internal", repository.Visibility)
+
+	// pushes that do not touch effx.yaml files are ignored
+	unrelated := strings.Replace(defaultBranch, `["effx.yaml"]`, `["README.md"]`, 1)
+	require.Equal(t, http.StatusOK, post("gitlab-token", unrelated))
+	require.Len(t, queue, 0)
+}
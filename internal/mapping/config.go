@@ -0,0 +1,54 @@
+package mapping
+
+import (
+	"github.com/urfave/cli/v2"
+)
+
+// Configuration encapsulates how repository metadata reported by an integration
+// is exposed as tags and annotations.
+type Configuration struct {
+	Tags        *cli.StringSlice
+	Annotations *cli.StringSlice
+	TopicTags   bool
+}
+
+// DefaultConfigWithFlags returns configuration and flags specific to metadata mapping.
+func DefaultConfigWithFlags() (*Configuration, []cli.Flag) {
+	cfg := &Configuration{
+		Tags: cli.NewStringSlice(
+			"owner=" + FieldOwner,
+		),
+		Annotations: cli.NewStringSlice(
+			"effx.io/repository-web-url="+FieldWebURL,
+			"effx.io/default-branch="+FieldDefaultBranch,
+			"effx.io/repository-visibility="+FieldVisibility,
+		),
+		TopicTags: true,
+	}
+
+	flags := []cli.Flag{
+		&cli.StringSliceFlag{
+			Name:        "tag-mapping",
+			Usage:       "tags populated from repository metadata, as tag=field",
+			Destination: cfg.Tags,
+			Value:       cfg.Tags,
+			EnvVars:     []string{"TAG_MAPPING"},
+		},
+		&cli.StringSliceFlag{
+			Name:        "annotation-mapping",
+			Usage:       "annotations populated from repository metadata, as annotation=field",
+			Destination: cfg.Annotations,
+			Value:       cfg.Annotations,
+			EnvVars:     []string{"ANNOTATION_MAPPING"},
+		},
+		&cli.BoolFlag{
+			Name:        "topic-tags",
+			Usage:       "add a tag for each topic of the repository",
+			Destination: &(cfg.TopicTags),
+			Value:       cfg.TopicTags,
+			EnvVars:     []string{"TOPIC_TAGS"},
+		},
+	}
+
+	return cfg, flags
+}
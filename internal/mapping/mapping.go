@@ -0,0 +1,109 @@
+package mapping
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/effxhq/vcs-connect/internal/model"
+)
+
+// Fields of a repository that can be mapped to tags and annotations.
+const (
+	FieldOwner         = "owner"
+	FieldFullName      = "full_name"
+	FieldDefaultBranch = "default_branch"
+	FieldVisibility    = "visibility"
+	FieldWebURL        = "web_url"
+	FieldCloneURL      = "clone_url"
+	FieldTopics        = "topics"
+)
+
+var fields = map[string]func(repository *model.Repository) string{
+	FieldOwner:         func(r *model.Repository) string { return r.Owner },
+	FieldFullName:      func(r *model.Repository) string { return r.FullName },
+	FieldDefaultBranch: func(r *model.Repository) string { return r.DefaultBranch },
+	FieldVisibility:    func(r *model.Repository) string { return r.Visibility },
+	FieldWebURL:        func(r *model.Repository) string { return r.WebURL },
+	FieldCloneURL:      func(r *model.Repository) string { return r.CloneURL },
+	FieldTopics:        func(r *model.Repository) string { return strings.Join(r.Topics, ",") },
+}
+
+// parse converts key=field pairs into a lookup from key to field.
+func parse(pairs []string) (map[string]string, error) {
+	parsed := make(map[string]string, len(pairs))
+	for _, pair := range pairs {
+		if pair == "" {
+			continue
+		}
+
+		parts := strings.SplitN(pair, "=", 2)
+		if len(parts) != 2 || parts[0] == "" {
+			return nil, fmt.Errorf("invalid mapping %s, expected key=field", pair)
+		} else if _, ok := fields[parts[1]]; !ok {
+			return nil, fmt.Errorf("invalid mapping %s, unknown field %s", pair, parts[1])
+		}
+
+		parsed[parts[0]] = parts[1]
+	}
+	return parsed, nil
+}
+
+// New returns a Mapping applying the rules of the provided configuration.
+func New(cfg *Configuration) (*Mapping, error) {
+	tags, err := parse(cfg.Tags.Value())
+	if err != nil {
+		return nil, err
+	}
+
+	annotations, err := parse(cfg.Annotations.Value())
+	if err != nil {
+		return nil, err
+	}
+
+	return &Mapping{
+		tags:        tags,
+		annotations: annotations,
+		topicTags:   cfg.TopicTags,
+	}, nil
+}
+
+// Mapping exposes repository metadata as tags and annotations.
+type Mapping struct {
+	tags        map[string]string
+	annotations map[string]string
+	topicTags   bool
+}
+
+func apply(target, mapping map[string]string, repository *model.Repository) {
+	for key, field := range mapping {
+		if _, ok := target[key]; ok {
+			continue
+		}
+
+		if value := fields[field](repository); value != "" {
+			target[key] = value
+		}
+	}
+}
+
+// Apply populates the tags and annotations of the repository from its metadata.
+// Tags and annotations already present on the repository are left untouched.
+func (m *Mapping) Apply(repository *model.Repository) {
+	if repository.Tags == nil {
+		repository.Tags = map[string]string{}
+	}
+	if repository.Annotations == nil {
+		repository.Annotations = map[string]string{}
+	}
+
+	apply(repository.Tags, m.tags, repository)
+	apply(repository.Annotations, m.annotations, repository)
+
+	if m.topicTags {
+		for _, topic := range repository.Topics {
+			if _, ok := repository.Tags[topic]; !ok {
+				repository.Tags[topic] = "true"
+			}
+		}
+	}
+}
@@ -0,0 +1,65 @@
+package mapping_test
+
+import (
+	"testing"
+
+	"github.com/effxhq/vcs-connect/internal/mapping"
+	"github.com/effxhq/vcs-connect/internal/model"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestMapping_Defaults(t *testing.T) {
+	cfg, _ := mapping.DefaultConfigWithFlags()
+
+	m, err := mapping.New(cfg)
+	require.NoError(t, err)
+
+	repository := &model.Repository{
+		Tags: map[string]string{
+			"owner": "platform",
+		},
+		Owner:         "effxhq",
+		DefaultBranch: "main",
+		Visibility:    "private",
+		WebURL:        "https://github.com/effxhq/vcs-connect",
+		Topics:        []string{"service"},
+	}
+
+	m.Apply(repository)
+
+	require.Equal(t, "platform", repository.Tags["owner"])
+	require.Equal(t, "true", repository.Tags["service"])
+	require.Equal(t, "https://github.com/effxhq/vcs-connect", repository.Annotations["effx.io/repository-web-url"])
+	require.Equal(t, "main", repository.Annotations["effx.io/default-branch"])
+	require.Equal(t, "private", repository.Annotations["effx.io/repository-visibility"])
+}
+
+func TestMapping_Custom(t *testing.T) {
+	cfg, _ := mapping.DefaultConfigWithFlags()
+	require.NoError(t, cfg.Tags.Set("namespace=owner"))
+	require.NoError(t, cfg.Annotations.Set(""))
+	cfg.TopicTags = false
+
+	m, err := mapping.New(cfg)
+	require.NoError(t, err)
+
+	repository := &model.Repository{
+		Owner:  "effxhq",
+		WebURL: "https://github.com/effxhq/vcs-connect",
+		Topics: []string{"service"},
+	}
+
+	m.Apply(repository)
+
+	require.Equal(t, map[string]string{"namespace": "effxhq"}, repository.Tags)
+	require.Empty(t, repository.Annotations)
+}
+
+func TestMapping_Invalid(t *testing.T) {
+	cfg, _ := mapping.DefaultConfigWithFlags()
+	require.NoError(t, cfg.Tags.Set("owner=unknown"))
+
+	_, err := mapping.New(cfg)
+	require.Error(t, err)
+}
@@ -0,0 +1,45 @@
+package metrics
+
+import (
+	"github.com/urfave/cli/v2"
+)
+
+// Configuration encapsulates information needed for exposing metrics.
+type Configuration struct {
+	Address        string
+	PushGatewayURL string
+	Job            string
+}
+
+// DefaultConfigWithFlags returns configuration and flags specific to metrics.
+func DefaultConfigWithFlags() (*Configuration, []cli.Flag) {
+	cfg := &Configuration{
+		Job: "vcs-connect",
+	}
+
+	flags := []cli.Flag{
+		&cli.StringFlag{
+			Name:        "metrics-address",
+			Usage:       "optional address serving Prometheus metrics on /metrics, such as :9090",
+			Destination: &(cfg.Address),
+			Value:       cfg.Address,
+			EnvVars:     []string{"METRICS_ADDRESS"},
+		},
+		&cli.StringFlag{
+			Name:        "metrics-pushgateway-url",
+			Usage:       "optional Prometheus Pushgateway metrics are pushed to when the run completes",
+			Destination: &(cfg.PushGatewayURL),
+			Value:       cfg.PushGatewayURL,
+			EnvVars:     []string{"METRICS_PUSHGATEWAY_URL"},
+		},
+		&cli.StringFlag{
+			Name:        "metrics-job",
+			Usage:       "job name metrics are pushed under",
+			Destination: &(cfg.Job),
+			Value:       cfg.Job,
+			EnvVars:     []string{"METRICS_JOB"},
+		},
+	}
+
+	return cfg, flags
+}
@@ -0,0 +1,87 @@
+package metrics
+
+import (
+	"net"
+	"net/http"
+	"strconv"
+
+	"github.com/pkg/errors"
+
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+	"github.com/prometheus/client_golang/prometheus/push"
+)
+
+// rateLimitHeaders lists the headers hosts use to report the remaining rate limit.
+var rateLimitHeaders = []string{"X-RateLimit-Remaining", "RateLimit-Remaining"}
+
+// Transport wraps the provided http.RoundTripper, counting requests made by the
+// integration and recording the rate limit reported in responses.
+func Transport(integration string, base http.RoundTripper) http.RoundTripper {
+	if base == nil {
+		base = http.DefaultTransport
+	}
+	return &transport{integration: integration, base: base}
+}
+
+type transport struct {
+	integration string
+	base        http.RoundTripper
+}
+
+func (t *transport) RoundTrip(req *http.Request) (*http.Response, error) {
+	resp, err := t.base.RoundTrip(req)
+	if err != nil {
+		APIRequests.WithLabelValues(t.integration, "error").Inc()
+		return resp, err
+	}
+
+	APIRequests.WithLabelValues(t.integration, strconv.Itoa(resp.StatusCode)).Inc()
+
+	for _, header := range rateLimitHeaders {
+		if remaining, err := strconv.ParseFloat(resp.Header.Get(header), 64); err == nil {
+			RateLimitRemaining.WithLabelValues(t.integration).Set(remaining)
+			break
+		}
+	}
+
+	return resp, nil
+}
+
+// Handler returns the http.Handler serving metrics in the Prometheus format.
+func Handler() http.Handler {
+	mux := http.NewServeMux()
+	mux.Handle("/metrics", promhttp.HandlerFor(Registry, promhttp.HandlerOpts{}))
+	return mux
+}
+
+// Listen binds the configured address, so failures to do so are reported before
+// any work starts. A nil listener is returned when no address is configured.
+func Listen(cfg *Configuration) (net.Listener, error) {
+	if cfg.Address == "" {
+		return nil, nil
+	}
+
+	listener, err := net.Listen("tcp", cfg.Address)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to listen for metrics")
+	}
+	return listener, nil
+}
+
+// Serve exposes metrics on the provided listener, blocking until the server fails.
+func Serve(listener net.Listener) error {
+	return http.Serve(listener, Handler())
+}
+
+// Push sends the current metrics to the configured Pushgateway, if any.
+func Push(cfg *Configuration) error {
+	if cfg.PushGatewayURL == "" {
+		return nil
+	}
+
+	err := push.New(cfg.PushGatewayURL, cfg.Job).Gatherer(Registry).Push()
+	if err != nil {
+		return errors.Wrap(err, "failed to push metrics")
+	}
+	return nil
+}
@@ -0,0 +1,132 @@
+package metrics
+
+import (
+	"github.com/effxhq/vcs-connect/internal/integrations"
+	"github.com/effxhq/vcs-connect/internal/model"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+)
+
+const namespace = "vcs_connect"
+
+var (
+	// Registry contains every metric exposed by vcs-connect.
+	Registry = prometheus.NewRegistry()
+
+	factory = promauto.With(Registry)
+
+	// RepositoriesDiscovered counts repositories found by each integration, before filtering.
+	RepositoriesDiscovered = factory.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "repositories_discovered_total",
+		Help:      "Repositories discovered by an integration, before filtering.",
+	}, []string{"integration"})
+
+	// RepositoriesSkipped counts repositories excluded before they were indexed.
+	RepositoriesSkipped = factory.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "repositories_skipped_total",
+		Help:      "Repositories excluded by filters or incremental indexing.",
+	}, []string{"integration"})
+
+	// RepositoriesIndexed counts repositories handed to workers by outcome.
+	RepositoriesIndexed = factory.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "repositories_indexed_total",
+		Help:      "Repositories indexed by workers, by status.",
+	}, []string{"status"})
+
+	// CloneDuration observes how long cloning a repository takes.
+	CloneDuration = factory.NewHistogram(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Name:      "clone_duration_seconds",
+		Help:      "Time spent cloning a repository.",
+		Buckets:   prometheus.ExponentialBuckets(0.25, 2, 12),
+	})
+
+	// CloneBytes observes the size of cloned repositories on disk.
+	CloneBytes = factory.NewHistogram(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Name:      "clone_bytes",
+		Help:      "Size of a cloned repository on disk.",
+		Buckets:   prometheus.ExponentialBuckets(64*1024, 4, 10),
+	})
+
+	// EffxYAMLFilesFound counts effx.yaml files found in indexed repositories.
+	EffxYAMLFilesFound = factory.NewCounter(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "effx_yaml_files_found_total",
+		Help:      "effx.yaml files found in indexed repositories.",
+	})
+
+	// EffxYAMLFilesUnchanged counts effx.yaml files skipped because they were synced recently with the same contents.
+	EffxYAMLFilesUnchanged = factory.NewCounter(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "effx_yaml_files_unchanged_total",
+		Help:      "effx.yaml files skipped because they were synced recently with the same contents.",
+	})
+
+	// SyncRequests counts requests to the effx API by status code, or "error" when no response was received.
+	SyncRequests = factory.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "sync_requests_total",
+		Help:      "Requests syncing or deleting effx.yaml files with the effx API, by status code.",
+	}, []string{"code"})
+
+	// APIRequests counts requests to version control APIs by status code.
+	APIRequests = factory.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "api_requests_total",
+		Help:      "Requests to version control APIs, by integration and status code.",
+	}, []string{"integration", "code"})
+
+	// RateLimitRemaining reports the requests remaining in the current rate limit window.
+	RateLimitRemaining = factory.NewGaugeVec(prometheus.GaugeOpts{
+		Namespace: namespace,
+		Name:      "api_rate_limit_remaining",
+		Help:      "Requests remaining in the rate limit window of a version control API.",
+	}, []string{"integration"})
+
+	// Workers reports the number of configured workers.
+	Workers = factory.NewGauge(prometheus.GaugeOpts{
+		Namespace: namespace,
+		Name:      "workers",
+		Help:      "Workers configured to index repositories.",
+	})
+
+	// WorkersBusy reports the number of workers currently indexing a repository.
+	WorkersBusy = factory.NewGauge(prometheus.GaugeOpts{
+		Namespace: namespace,
+		Name:      "workers_busy",
+		Help:      "Workers currently indexing a repository.",
+	})
+)
+
+func init() {
+	Registry.MustRegister(
+		prometheus.NewGoCollector(),
+		prometheus.NewProcessCollector(prometheus.ProcessCollectorOpts{}),
+	)
+}
+
+// Filter wraps the provided filter, counting the repositories discovered and
+// skipped by the integration.
+func Filter(integration string, filter integrations.Filter) integrations.Filter {
+	return &countingFilter{integration: integration, filter: filter}
+}
+
+type countingFilter struct {
+	integration string
+	filter      integrations.Filter
+}
+
+func (c *countingFilter) Reason(repository *model.Repository) string {
+	RepositoriesDiscovered.WithLabelValues(c.integration).Inc()
+
+	reason := c.filter.Reason(repository)
+	if reason != "" {
+		RepositoriesSkipped.WithLabelValues(c.integration).Inc()
+	}
+	return reason
+}
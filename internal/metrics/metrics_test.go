@@ -0,0 +1,71 @@
+package metrics_test
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/effxhq/vcs-connect/internal/metrics"
+	"github.com/effxhq/vcs-connect/internal/model"
+
+	"github.com/prometheus/client_golang/prometheus/testutil"
+
+	"github.com/stretchr/testify/require"
+)
+
+type excludeForks struct{}
+
+func (excludeForks) Reason(repository *model.Repository) string {
+	if repository.Fork {
+		return "repository is a fork"
+	}
+	return ""
+}
+
+func TestFilter(t *testing.T) {
+	filter := metrics.Filter("test-filter", excludeForks{})
+
+	require.Equal(t, "", filter.Reason(&model.Repository{}))
+	require.Equal(t, "repository is a fork", filter.Reason(&model.Repository{Fork: true}))
+
+	require.Equal(t, 2.0, testutil.ToFloat64(metrics.RepositoriesDiscovered.WithLabelValues("test-filter")))
+	require.Equal(t, 1.0, testutil.ToFloat64(metrics.RepositoriesSkipped.WithLabelValues("test-filter")))
+}
+
+func TestTransport(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("RateLimit-Remaining", "42")
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer server.Close()
+
+	client := &http.Client{Transport: metrics.Transport("test-transport", nil)}
+
+	resp, err := client.Get(server.URL)
+	require.NoError(t, err)
+	resp.Body.Close()
+
+	require.Equal(t, 1.0, testutil.ToFloat64(metrics.APIRequests.WithLabelValues("test-transport", "200")))
+	require.Equal(t, 42.0, testutil.ToFloat64(metrics.RateLimitRemaining.WithLabelValues("test-transport")))
+}
+
+func TestListen(t *testing.T) {
+	listener, err := metrics.Listen(&metrics.Configuration{})
+	require.NoError(t, err)
+	require.Nil(t, listener)
+
+	listener, err = metrics.Listen(&metrics.Configuration{Address: "127.0.0.1:0"})
+	require.NoError(t, err)
+	defer listener.Close()
+
+	go metrics.Serve(listener)
+
+	resp, err := http.Get("http://" + listener.Addr().String() + "/metrics")
+	require.NoError(t, err)
+	resp.Body.Close()
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+
+	// addresses already in use are reported instead of failing in the background
+	_, err = metrics.Listen(&metrics.Configuration{Address: listener.Addr().String()})
+	require.Error(t, err)
+}
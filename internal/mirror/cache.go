@@ -0,0 +1,297 @@
+package mirror
+
+import (
+	"context"
+	"crypto/sha256"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/effxhq/vcs-connect/internal/tracing"
+
+	"github.com/pkg/errors"
+
+	"gopkg.in/src-d/go-billy.v4/osfs"
+	"gopkg.in/src-d/go-git.v4"
+	"gopkg.in/src-d/go-git.v4/config"
+	"gopkg.in/src-d/go-git.v4/plumbing"
+	"gopkg.in/src-d/go-git.v4/plumbing/transport"
+)
+
+// fetches every branch into the branches of the mirror, so whichever branch is the
+// default one is current. Fetching never removes branches, see prune.
+var refSpecs = []config.RefSpec{"+refs/heads/*:refs/heads/*"}
+
+// dirSize returns the number of bytes used by files in the directory.
+func dirSize(dir string) int64 {
+	var size int64
+	filepath.Walk(dir, func(path string, info os.FileInfo, err error) error {
+		if err == nil && !info.IsDir() {
+			size += info.Size()
+		}
+		return nil
+	})
+	return size
+}
+
+// mirror tracks a single bare repository in the cache.
+type mirror struct {
+	// held while the mirror is fetched and checked out
+	mu sync.Mutex
+
+	size   int64
+	usedAt time.Time
+	inUse  int
+}
+
+// Open returns a Cache of the mirrors kept in the configured directory by previous runs.
+func Open(cfg *Configuration) (*Cache, error) {
+	if err := cfg.Validate(); err != nil {
+		return nil, err
+	}
+
+	if err := os.MkdirAll(cfg.Dir, 0755); err != nil {
+		return nil, errors.Wrap(err, "failed to create mirror directory")
+	}
+
+	infos, err := ioutil.ReadDir(cfg.Dir)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to read mirror directory")
+	}
+
+	mirrors := make(map[string]*mirror)
+	for _, info := range infos {
+		if info.IsDir() {
+			mirrors[info.Name()] = &mirror{
+				size:   dirSize(filepath.Join(cfg.Dir, info.Name())),
+				usedAt: info.ModTime(),
+			}
+		}
+	}
+
+	return &Cache{
+		cfg:     cfg,
+		mirrors: mirrors,
+	}, nil
+}
+
+// Cache keeps a bare mirror of each repository under a directory, so later runs
+// only fetch new objects instead of cloning the repository again. Mirrors are
+// evicted least recently used first once the directory exceeds its size limit.
+// It is safe for concurrent use.
+type Cache struct {
+	cfg *Configuration
+
+	mu      sync.Mutex
+	mirrors map[string]*mirror
+}
+
+func (c *Cache) acquire(name string) *mirror {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	m := c.mirrors[name]
+	if m == nil {
+		m = &mirror{}
+		c.mirrors[name] = m
+	}
+	m.inUse++
+	return m
+}
+
+func (c *Cache) release(m *mirror) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	m.inUse--
+}
+
+// update fetches new objects into the mirror, cloning it when it does not exist
+// or cannot be opened.
+func (c *Cache) update(ctx context.Context, dir, cloneURL string, auth transport.AuthMethod) (*git.Repository, error) {
+	repo, err := git.PlainOpen(dir)
+	if err == nil {
+		err = repo.FetchContext(ctx, &git.FetchOptions{
+			RefSpecs: refSpecs,
+			Auth:     auth,
+			Force:    true,
+		})
+		if err != nil && err != git.NoErrAlreadyUpToDate {
+			return nil, errors.Wrap(err, "failed to fetch repository")
+		}
+
+		if err := prune(repo, auth); err != nil {
+			return nil, err
+		}
+		return repo, nil
+	} else if err != git.ErrRepositoryNotExists {
+		// likely left behind by an interrupted clone
+		if err := os.RemoveAll(dir); err != nil {
+			return nil, errors.Wrap(err, "failed to remove broken mirror")
+		}
+	}
+
+	repo, err = git.PlainCloneContext(ctx, dir, true, &git.CloneOptions{
+		URL:  cloneURL,
+		Auth: auth,
+	})
+	if err != nil {
+		os.RemoveAll(dir)
+		return nil, errors.Wrap(err, "failed to clone repository")
+	}
+	return repo, nil
+}
+
+// prune removes the branches of the mirror deleted from the remote, and points
+// HEAD at the remote's default branch, as neither is done by fetching.
+func prune(repo *git.Repository, auth transport.AuthMethod) error {
+	remote, err := repo.Remote(git.DefaultRemoteName)
+	if err != nil {
+		return err
+	}
+
+	refs, err := remote.List(&git.ListOptions{Auth: auth})
+	if err != nil {
+		return errors.Wrap(err, "failed to list remote references")
+	}
+
+	remoteRefs := make(map[plumbing.ReferenceName]bool, len(refs))
+	for _, ref := range refs {
+		remoteRefs[ref.Name()] = true
+
+		if ref.Name() == plumbing.HEAD && ref.Type() == plumbing.SymbolicReference {
+			if err := repo.Storer.SetReference(ref); err != nil {
+				return errors.Wrap(err, "failed to update HEAD")
+			}
+		}
+	}
+
+	branches, err := repo.Branches()
+	if err != nil {
+		return err
+	}
+
+	return branches.ForEach(func(branch *plumbing.Reference) error {
+		if remoteRefs[branch.Name()] {
+			return nil
+		}
+		return errors.Wrapf(repo.Storer.RemoveReference(branch.Name()), "failed to prune %s", branch.Name())
+	})
+}
+
+// resolve returns the branch to check out, which is the default branch reported
+// by the host when known, and otherwise the branch HEAD of the remote points at.
+func resolve(repo *git.Repository, defaultBranch string) (*plumbing.Reference, error) {
+	if defaultBranch == "" {
+		head, err := repo.Head()
+		return head, errors.Wrap(err, "failed to resolve HEAD")
+	}
+
+	ref, err := repo.Reference(plumbing.NewBranchReferenceName(defaultBranch), true)
+	return ref, errors.Wrapf(err, "failed to resolve default branch %s", defaultBranch)
+}
+
+// Checkout updates the mirror of the repository and checks out its default
+// branch into the work directory, returning the commit checked out. When the
+// default branch is unknown, the branch HEAD of the remote points at is used.
+func (c *Cache) Checkout(ctx context.Context, cloneURL, defaultBranch, workDir string, auth transport.AuthMethod) (commit string, err error) {
+	ctx, span := tracing.Start(ctx, "mirror.Checkout")
+	defer func() { tracing.End(span, err) }()
+
+	name := fmt.Sprintf("%x", sha256.Sum256([]byte(cloneURL)))
+	dir := filepath.Join(c.cfg.Dir, name)
+
+	m := c.acquire(name)
+	defer c.release(m)
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	repo, err := c.update(ctx, dir, cloneURL, auth)
+	if err != nil {
+		return "", err
+	}
+
+	head, err := resolve(repo, defaultBranch)
+	if err != nil {
+		return "", err
+	}
+
+	// resetting moves the branch HEAD points at, so point it at the branch being
+	// checked out, which then remains unchanged
+	err = repo.Storer.SetReference(plumbing.NewSymbolicReference(plumbing.HEAD, head.Name()))
+	if err != nil {
+		return "", errors.Wrap(err, "failed to update HEAD")
+	}
+
+	// share the objects of the mirror with a worktree in the work directory
+	checkout, err := git.Open(repo.Storer, osfs.New(workDir))
+	if err != nil {
+		return "", err
+	}
+
+	worktree, err := checkout.Worktree()
+	if err != nil {
+		return "", err
+	}
+
+	err = worktree.Reset(&git.ResetOptions{
+		Commit: head.Hash(),
+		Mode:   git.HardReset,
+	})
+	if err != nil {
+		return "", errors.Wrap(err, "failed to checkout repository")
+	}
+
+	now := time.Now()
+	os.Chtimes(dir, now, now)
+
+	c.mu.Lock()
+	m.size = dirSize(dir)
+	m.usedAt = now
+	c.mu.Unlock()
+
+	c.evict()
+	return head.Hash().String(), nil
+}
+
+// evict removes the least recently used mirrors that are not in use until the
+// cache fits within its size limit.
+func (c *Cache) evict() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	var total int64
+	names := make([]string, 0, len(c.mirrors))
+	for name, m := range c.mirrors {
+		total += m.size
+		names = append(names, name)
+	}
+
+	sort.Slice(names, func(i, j int) bool {
+		return c.mirrors[names[i]].usedAt.Before(c.mirrors[names[j]].usedAt)
+	})
+
+	limit := c.cfg.MaxSizeMB << 20
+	for _, name := range names {
+		if total <= limit {
+			return
+		}
+
+		m := c.mirrors[name]
+		if m.inUse > 0 {
+			continue
+		}
+
+		if err := os.RemoveAll(filepath.Join(c.cfg.Dir, name)); err != nil {
+			continue
+		}
+
+		total -= m.size
+		delete(c.mirrors, name)
+	}
+}
@@ -0,0 +1,142 @@
+package mirror_test
+
+import (
+	"context"
+	"crypto/rand"
+	"io/ioutil"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/effxhq/vcs-connect/internal/mirror"
+
+	"github.com/stretchr/testify/require"
+
+	"gopkg.in/src-d/go-git.v4"
+	"gopkg.in/src-d/go-git.v4/plumbing"
+)
+
+// gitCommand runs the git binary in the repository, which also serves the
+// repository when it is cloned from a local path.
+func gitCommand(t *testing.T, repoDir string, args ...string) string {
+	out, err := exec.Command("git", append([]string{"-C", repoDir}, args...)...).CombinedOutput()
+	require.NoError(t, err, string(out))
+	return strings.TrimSpace(string(out))
+}
+
+// commitFile commits a file to the repository.
+func commitFile(t *testing.T, repoDir, name, contents string) {
+	require.NoError(t, ioutil.WriteFile(filepath.Join(repoDir, name), []byte(contents), 0644))
+
+	gitCommand(t, repoDir, "add", name)
+	gitCommand(t, repoDir, "-c", "user.name=test", "-c", "user.email=test@effx.io", "commit", "-q", "-m", name)
+}
+
+func newRepository(t *testing.T) string {
+	repoDir := t.TempDir()
+	out, err := exec.Command("git", "init", "-q", repoDir).CombinedOutput()
+	require.NoError(t, err, string(out))
+	return repoDir
+}
+
+func TestCache_Checkout(t *testing.T) {
+	if _, err := exec.LookPath("git"); err != nil {
+		t.Skip("git is required to serve local repositories")
+	}
+
+	ctx := context.Background()
+	cfg := &mirror.Configuration{
+		Dir:       t.TempDir(),
+		MaxSizeMB: 1,
+	}
+
+	repoDir := newRepository(t)
+	commitFile(t, repoDir, "effx.yaml", "version: effx/v1\n")
+
+	cache, err := mirror.Open(cfg)
+	require.NoError(t, err)
+
+	workDir := t.TempDir()
+	first, err := cache.Checkout(ctx, repoDir, "", workDir, nil)
+	require.NoError(t, err)
+	require.NotEmpty(t, first)
+	require.FileExists(t, filepath.Join(workDir, "effx.yaml"))
+
+	// later checkouts fetch new commits into the existing mirror
+	commitFile(t, repoDir, "api.effx.yaml", "version: effx/v1\n")
+
+	cache, err = mirror.Open(cfg)
+	require.NoError(t, err)
+
+	workDir = t.TempDir()
+	second, err := cache.Checkout(ctx, repoDir, "", workDir, nil)
+	require.NoError(t, err)
+	require.NotEqual(t, first, second)
+	require.FileExists(t, filepath.Join(workDir, "effx.yaml"))
+	require.FileExists(t, filepath.Join(workDir, "api.effx.yaml"))
+
+	// least recently used mirrors are evicted once the cache is full
+	otherDir := newRepository(t)
+	large := make([]byte, 2<<20)
+	rand.Read(large)
+	commitFile(t, otherDir, "large.bin", string(large))
+
+	_, err = cache.Checkout(ctx, otherDir, "", t.TempDir(), nil)
+	require.NoError(t, err)
+
+	mirrors, err := ioutil.ReadDir(cfg.Dir)
+	require.NoError(t, err)
+	require.Len(t, mirrors, 1)
+}
+
+func TestCache_CheckoutRenamedBranch(t *testing.T) {
+	if _, err := exec.LookPath("git"); err != nil {
+		t.Skip("git is required to serve local repositories")
+	}
+
+	ctx := context.Background()
+	cfg := &mirror.Configuration{
+		Dir:       t.TempDir(),
+		MaxSizeMB: 1,
+	}
+
+	repoDir := newRepository(t)
+	commitFile(t, repoDir, "effx.yaml", "version: effx/v1\n")
+	initial := gitCommand(t, repoDir, "symbolic-ref", "--short", "HEAD")
+
+	cache, err := mirror.Open(cfg)
+	require.NoError(t, err)
+
+	_, err = cache.Checkout(ctx, repoDir, initial, t.TempDir(), nil)
+	require.NoError(t, err)
+
+	// the default branch is renamed upstream, and moves ahead
+	gitCommand(t, repoDir, "branch", "-m", "trunk")
+	commitFile(t, repoDir, "api.effx.yaml", "version: effx/v1\n")
+
+	workDir := t.TempDir()
+	commit, err := cache.Checkout(ctx, repoDir, "trunk", workDir, nil)
+	require.NoError(t, err)
+	require.Equal(t, gitCommand(t, repoDir, "rev-parse", "HEAD"), commit)
+	require.FileExists(t, filepath.Join(workDir, "api.effx.yaml"))
+
+	mirrors, err := ioutil.ReadDir(cfg.Dir)
+	require.NoError(t, err)
+	require.Len(t, mirrors, 1)
+
+	repo, err := git.PlainOpen(filepath.Join(cfg.Dir, mirrors[0].Name()))
+	require.NoError(t, err)
+
+	// the branch deleted upstream is pruned from the mirror
+	_, err = repo.Reference(plumbing.NewBranchReferenceName(initial), false)
+	require.Equal(t, plumbing.ErrReferenceNotFound, err)
+
+	// without a default branch, the branch HEAD of the remote points at is checked out
+	commitFile(t, repoDir, "web.effx.yaml", "version: effx/v1\n")
+
+	workDir = t.TempDir()
+	_, err = cache.Checkout(ctx, repoDir, "", workDir, nil)
+	require.NoError(t, err)
+	require.FileExists(t, filepath.Join(workDir, "web.effx.yaml"))
+}
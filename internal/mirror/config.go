@@ -0,0 +1,59 @@
+package mirror
+
+import (
+	"fmt"
+
+	"github.com/urfave/cli/v2"
+)
+
+// Configuration encapsulates information needed for keeping mirrors of cloned
+// repositories between runs.
+type Configuration struct {
+	Enabled bool
+	Dir     string
+	// MaxSizeMB bounds the size of the mirror directory, evicting the least recently used mirrors.
+	MaxSizeMB int64
+}
+
+// Validate ensures the configuration provided contains the required information.
+func (c *Configuration) Validate() error {
+	if c.Dir == "" {
+		return fmt.Errorf("a mirror directory must be provided")
+	} else if c.MaxSizeMB <= 0 {
+		return fmt.Errorf("the mirror cache size must be positive")
+	}
+	return nil
+}
+
+// DefaultConfigWithFlags returns configuration and flags specific to the mirror cache.
+func DefaultConfigWithFlags() (*Configuration, []cli.Flag) {
+	cfg := &Configuration{
+		MaxSizeMB: 10240,
+	}
+
+	flags := []cli.Flag{
+		&cli.BoolFlag{
+			Name:        "mirror-cache",
+			Usage:       "keep a bare mirror of each repository, fetching only new commits on later runs",
+			Destination: &(cfg.Enabled),
+			Value:       cfg.Enabled,
+			EnvVars:     []string{"MIRROR_CACHE"},
+		},
+		&cli.StringFlag{
+			Name:        "mirror-cache-dir",
+			Usage:       "directory mirrors are kept in, defaults to mirrors in the scratch dir",
+			Destination: &(cfg.Dir),
+			Value:       cfg.Dir,
+			EnvVars:     []string{"MIRROR_CACHE_DIR"},
+		},
+		&cli.Int64Flag{
+			Name:        "mirror-cache-max-size",
+			Usage:       "megabytes mirrors may use before the least recently used are removed",
+			Destination: &(cfg.MaxSizeMB),
+			Value:       cfg.MaxSizeMB,
+			EnvVars:     []string{"MIRROR_CACHE_MAX_SIZE"},
+		},
+	}
+
+	return cfg, flags
+}
@@ -0,0 +1,16 @@
+package model
+
+import (
+	"path/filepath"
+	"regexp"
+)
+
+var (
+	// matches *.effx.yaml, effx.yaml, *.effx.yml, effx.yml
+	effxYAMLPattern, _ = regexp.Compile("^(.+\\.)?effx\\.ya?ml$")
+)
+
+// IsEffxYAML reports whether the file at the provided path is an effx.yaml file.
+func IsEffxYAML(filePath string) bool {
+	return effxYAMLPattern.MatchString(filepath.Base(filePath))
+}
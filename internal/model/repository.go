@@ -1,11 +1,48 @@
 package model
 
+import (
+	"time"
+)
+
 // Repository represents a source potentially containing effx.yaml files
 type Repository struct {
 	// CloneURL defines a target used to pull down source code.
 	CloneURL string
+	// SSHURL defines an alternative target used to pull down source code over SSH.
+	SSHURL string
+	// WorkDir optionally points at an existing checkout of the repository. When set,
+	// the repository is indexed in place and is neither cloned nor cleaned up.
+	WorkDir string
 	// Tags common to both teams and services discovered by this integration.
 	Tags map[string]string
 	// Annotations common to both teams and services discovered by this integration.
 	Annotations map[string]string
+
+	// FullName identifies the repository within its host, for example org/repo.
+	FullName string
+	// Owner is the organization, group or namespace the repository belongs to.
+	Owner string
+	// DefaultBranch is the branch checked out when cloning the repository.
+	DefaultBranch string
+	// WebURL links to the repository in the user interface of its host.
+	WebURL string
+	// Visibility is one of public, internal or private when reported by the host.
+	Visibility string
+	// Topics the repository has been labeled with.
+	Topics []string
+	// Archived repositories are read-only.
+	Archived bool
+	// Fork repositories were copied from another repository.
+	Fork bool
+	// Template repositories are used to generate new repositories.
+	Template bool
+	// Mirror repositories are synchronized from another remote.
+	Mirror bool
+	// Empty repositories do not contain any commits.
+	Empty bool
+	// PushedAt is the last time commits were pushed, when reported by the host.
+	PushedAt time.Time
+
+	// Source optionally reads files through the API of the host instead of cloning the repository.
+	Source Source
 }
@@ -0,0 +1,22 @@
+package model
+
+import (
+	"context"
+)
+
+// Tree lists the files of a repository at a single commit.
+type Tree struct {
+	// Commit the files were listed at.
+	Commit string
+	// Blobs maps the path of each file, relative to the root of the repository, to the id of its contents.
+	Blobs map[string]string
+}
+
+// Source reads the files of a repository through the API of its host, so the
+// repository can be indexed without being cloned.
+type Source interface {
+	// Tree lists the files on the default branch of the repository.
+	Tree(ctx context.Context) (*Tree, error)
+	// ReadBlob returns the contents of a file listed in a tree.
+	ReadBlob(ctx context.Context, id string) ([]byte, error)
+}
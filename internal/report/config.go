@@ -0,0 +1,45 @@
+package report
+
+import (
+	"fmt"
+
+	"github.com/urfave/cli/v2"
+)
+
+// Configuration encapsulates information needed for reporting the outcome of a run.
+type Configuration struct {
+	File             string
+	FailureThreshold float64
+}
+
+// Validate ensures the configuration provided contains the required information.
+func (c *Configuration) Validate() error {
+	if c.FailureThreshold < 0 || c.FailureThreshold > 1 {
+		return fmt.Errorf("the failure threshold must be between 0 and 1")
+	}
+	return nil
+}
+
+// DefaultConfigWithFlags returns configuration and flags specific to run reports.
+func DefaultConfigWithFlags() (*Configuration, []cli.Flag) {
+	cfg := &Configuration{}
+
+	flags := []cli.Flag{
+		&cli.StringFlag{
+			Name:        "report-file",
+			Usage:       "optional file the JSON report of each run is written to",
+			Destination: &(cfg.File),
+			Value:       cfg.File,
+			EnvVars:     []string{"REPORT_FILE"},
+		},
+		&cli.Float64Flag{
+			Name:        "failure-threshold",
+			Usage:       "fraction of indexed repositories that may fail before the run exits with an error",
+			Destination: &(cfg.FailureThreshold),
+			Value:       cfg.FailureThreshold,
+			EnvVars:     []string{"FAILURE_THRESHOLD"},
+		},
+	}
+
+	return cfg, flags
+}
@@ -0,0 +1,194 @@
+package report
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"sync"
+	"time"
+
+	"github.com/effxhq/vcs-connect/internal/integrations"
+	"github.com/effxhq/vcs-connect/internal/model"
+
+	"github.com/pkg/errors"
+
+	"go.uber.org/zap"
+)
+
+const (
+	// StatusSynced is reported when every effx.yaml file in the repository was synced.
+	StatusSynced = "synced"
+	// StatusFailed is reported when the repository could not be indexed, or a file failed to sync.
+	StatusFailed = "failed"
+	// StatusSkipped is reported when the repository was excluded before it was indexed.
+	StatusSkipped = "skipped"
+)
+
+// Outcome describes what happened to a single repository during a run.
+type Outcome struct {
+	Repository      string  `json:"repository"`
+	Status          string  `json:"status"`
+	Reason          string  `json:"reason,omitempty"`
+	Cloned          bool    `json:"cloned"`
+	FilesFound      int     `json:"filesFound"`
+	FilesSynced     int     `json:"filesSynced"`
+	FilesFailed     int     `json:"filesFailed"`
+	FilesUnchanged  int     `json:"filesUnchanged"`
+	FilesDeleted    int     `json:"filesDeleted"`
+	DurationSeconds float64 `json:"durationSeconds"`
+	Error           string  `json:"error,omitempty"`
+}
+
+// Summary aggregates the outcomes of a run.
+type Summary struct {
+	StartedAt       time.Time  `json:"startedAt"`
+	FinishedAt      time.Time  `json:"finishedAt"`
+	DurationSeconds float64    `json:"durationSeconds"`
+	Synced          int        `json:"synced"`
+	Failed          int        `json:"failed"`
+	Skipped         int        `json:"skipped"`
+	FilesFound      int        `json:"filesFound"`
+	FilesSynced     int        `json:"filesSynced"`
+	FilesFailed     int        `json:"filesFailed"`
+	FilesUnchanged  int        `json:"filesUnchanged"`
+	FilesDeleted    int        `json:"filesDeleted"`
+	Outcomes        []*Outcome `json:"outcomes"`
+}
+
+// FailureRate returns the fraction of indexed repositories that failed.
+func (s *Summary) FailureRate() float64 {
+	indexed := s.Synced + s.Failed
+	if indexed == 0 {
+		return 0
+	}
+	return float64(s.Failed) / float64(indexed)
+}
+
+// New returns a Report using the provided configuration.
+func New(cfg *Configuration) (*Report, error) {
+	if err := cfg.Validate(); err != nil {
+		return nil, err
+	}
+
+	return &Report{
+		cfg:       cfg,
+		startedAt: time.Now().UTC(),
+	}, nil
+}
+
+// Report collects the outcome of every repository in a run. It is safe for concurrent use.
+type Report struct {
+	cfg *Configuration
+
+	mu        sync.Mutex
+	startedAt time.Time
+	outcomes  []*Outcome
+}
+
+// Begin discards previous outcomes and marks the start of a new run.
+func (r *Report) Begin() {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.startedAt = time.Now().UTC()
+	r.outcomes = nil
+}
+
+// Record adds the outcome of a repository to the run.
+func (r *Report) Record(outcome *Outcome) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.outcomes = append(r.outcomes, outcome)
+}
+
+// Filter wraps the provided filter, recording repositories it excludes as skipped.
+func (r *Report) Filter(filter integrations.Filter) integrations.Filter {
+	return &skipRecorder{report: r, filter: filter}
+}
+
+type skipRecorder struct {
+	report *Report
+	filter integrations.Filter
+}
+
+func (s *skipRecorder) Reason(repository *model.Repository) string {
+	reason := s.filter.Reason(repository)
+	if reason != "" {
+		s.report.Record(&Outcome{
+			Repository: repository.CloneURL,
+			Status:     StatusSkipped,
+			Reason:     reason,
+		})
+	}
+	return reason
+}
+
+// Summarize aggregates the outcomes recorded since the run began.
+func (r *Report) Summarize() *Summary {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	finishedAt := time.Now().UTC()
+	summary := &Summary{
+		StartedAt:       r.startedAt,
+		FinishedAt:      finishedAt,
+		DurationSeconds: finishedAt.Sub(r.startedAt).Seconds(),
+		Outcomes:        make([]*Outcome, len(r.outcomes)),
+	}
+	copy(summary.Outcomes, r.outcomes)
+
+	for _, outcome := range r.outcomes {
+		switch outcome.Status {
+		case StatusSynced:
+			summary.Synced++
+		case StatusFailed:
+			summary.Failed++
+		case StatusSkipped:
+			summary.Skipped++
+		}
+
+		summary.FilesFound += outcome.FilesFound
+		summary.FilesSynced += outcome.FilesSynced
+		summary.FilesFailed += outcome.FilesFailed
+		summary.FilesUnchanged += outcome.FilesUnchanged
+		summary.FilesDeleted += outcome.FilesDeleted
+	}
+
+	return summary
+}
+
+// End summarizes the run, logs the summary and writes it to the configured file.
+// An error is returned when more repositories failed than the threshold allows.
+func (r *Report) End(log *zap.Logger) error {
+	summary := r.Summarize()
+
+	log.Info("run summary",
+		zap.Int("synced", summary.Synced),
+		zap.Int("failed", summary.Failed),
+		zap.Int("skipped", summary.Skipped),
+		zap.Int("filesFound", summary.FilesFound),
+		zap.Int("filesSynced", summary.FilesSynced),
+		zap.Int("filesFailed", summary.FilesFailed),
+		zap.Int("filesUnchanged", summary.FilesUnchanged),
+		zap.Int("filesDeleted", summary.FilesDeleted),
+		zap.Float64("durationSeconds", summary.DurationSeconds))
+
+	if r.cfg.File != "" {
+		body, err := json.MarshalIndent(summary, "", "  ")
+		if err != nil {
+			return errors.Wrap(err, "failed to encode report")
+		}
+
+		if err := ioutil.WriteFile(r.cfg.File, body, 0644); err != nil {
+			return errors.Wrap(err, "failed to write report")
+		}
+	}
+
+	if rate := summary.FailureRate(); summary.Failed > 0 && rate > r.cfg.FailureThreshold {
+		return fmt.Errorf("%d of %d repositories failed, exceeding the failure threshold of %.0f%%",
+			summary.Failed, summary.Synced+summary.Failed, r.cfg.FailureThreshold*100)
+	}
+
+	return nil
+}
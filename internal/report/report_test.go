@@ -0,0 +1,58 @@
+package report_test
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"path"
+	"testing"
+
+	"github.com/effxhq/vcs-connect/internal/integrations"
+	"github.com/effxhq/vcs-connect/internal/model"
+	"github.com/effxhq/vcs-connect/internal/report"
+
+	"github.com/stretchr/testify/require"
+
+	"go.uber.org/zap"
+)
+
+type excludeAll struct{}
+
+func (excludeAll) Reason(repository *model.Repository) string {
+	return "excluded"
+}
+
+func TestReport(t *testing.T) {
+	file := path.Join(t.TempDir(), "report.json")
+
+	rep, err := report.New(&report.Configuration{File: file, FailureThreshold: 0.5})
+	require.NoError(t, err)
+
+	rep.Begin()
+	rep.Record(&report.Outcome{Repository: "a", Status: report.StatusSynced, FilesFound: 2, FilesSynced: 2})
+	rep.Record(&report.Outcome{Repository: "b", Status: report.StatusFailed, FilesFound: 1, FilesFailed: 1})
+
+	var filter integrations.Filter = rep.Filter(excludeAll{})
+	require.Equal(t, "excluded", filter.Reason(&model.Repository{CloneURL: "c"}))
+
+	// one of two indexed repositories failing is within the threshold
+	require.NoError(t, rep.End(zap.NewNop()))
+
+	body, err := ioutil.ReadFile(file)
+	require.NoError(t, err)
+
+	summary := &report.Summary{}
+	require.NoError(t, json.Unmarshal(body, summary))
+	require.Equal(t, 1, summary.Synced)
+	require.Equal(t, 1, summary.Failed)
+	require.Equal(t, 1, summary.Skipped)
+	require.Equal(t, 3, summary.FilesFound)
+	require.Len(t, summary.Outcomes, 3)
+
+	rep.Record(&report.Outcome{Repository: "d", Status: report.StatusFailed, Error: "clone failed"})
+	require.Error(t, rep.End(zap.NewNop()))
+
+	// a new run starts without previous outcomes
+	rep.Begin()
+	require.NoError(t, rep.End(zap.NewNop()))
+	require.Len(t, rep.Summarize().Outcomes, 0)
+}
@@ -8,16 +8,28 @@ import (
 	"os"
 	"path"
 	"path/filepath"
-	"regexp"
+	"sort"
 	"strings"
+	"time"
 
 	"github.com/effxhq/effx-cli/metadata"
 	"github.com/effxhq/vcs-connect/internal/effx"
 	"github.com/effxhq/vcs-connect/internal/logger"
+	"github.com/effxhq/vcs-connect/internal/mapping"
+	"github.com/effxhq/vcs-connect/internal/metrics"
+	"github.com/effxhq/vcs-connect/internal/mirror"
 	"github.com/effxhq/vcs-connect/internal/model"
+	"github.com/effxhq/vcs-connect/internal/report"
+	"github.com/effxhq/vcs-connect/internal/state"
+	"github.com/effxhq/vcs-connect/internal/synccache"
+	"github.com/effxhq/vcs-connect/internal/tracing"
 
 	"github.com/pkg/errors"
 
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+
+	"go.uber.org/multierr"
 	"go.uber.org/zap"
 
 	"gopkg.in/src-d/go-billy.v4/osfs"
@@ -27,11 +39,6 @@ import (
 	"gopkg.in/src-d/go-git.v4/storage/filesystem"
 )
 
-var (
-	// matches *.effx.yaml, effx.yaml, *.effx.yml, effx.yml
-	effxYAMLPattern, _ = regexp.Compile("^(.+\\.)?effx\\.ya?ml$")
-)
-
 func s256(in string) string {
 	return fmt.Sprintf("%x", sha256.Sum256([]byte(in)))
 }
@@ -44,15 +51,65 @@ func cloneMap(in map[string]string) map[string]string {
 	return out
 }
 
+// refresher is implemented by authentication methods using short-lived credentials.
+type refresher interface {
+	Refresh(ctx context.Context) error
+	// Err returns the last failure to obtain credentials, including those while
+	// attaching them to requests mid-clone.
+	Err() error
+}
+
 // Consumer is a stateless entity that ingests repositories from integrations.
 type Consumer struct {
 	EffxClient *effx.Client
 	ScratchDir string
 	AuthMethod transport.AuthMethod
+	// CloneOverSSH prefers the SSH url of a repository when one is available.
+	CloneOverSSH bool
+	// Mapping optionally exposes repository metadata as tags and annotations.
+	Mapping *mapping.Mapping
+	// State optionally records repositories that were indexed successfully.
+	State *state.Store
+	// Mirrors optionally keeps repositories between runs, so only new commits are fetched.
+	Mirrors *mirror.Cache
+	// SyncCache optionally skips effx.yaml files recently synced with the same request.
+	SyncCache *synccache.Cache
+	// Report optionally collects the outcome of each repository.
+	Report *report.Report
+}
+
+// headCommit returns the commit checked out in the work directory, or an empty
+// string if it cannot be determined.
+func headCommit(workDir string) string {
+	repo, err := git.PlainOpenWithOptions(workDir, &git.PlainOpenOptions{DetectDotGit: true})
+	if err != nil {
+		return ""
+	}
+
+	head, err := repo.Head()
+	if err != nil {
+		return ""
+	}
+	return head.Hash().String()
+}
+
+// dirSize returns the number of bytes used by files in the directory.
+func dirSize(dir string) int64 {
+	var size int64
+	filepath.Walk(dir, func(path string, info os.FileInfo, err error) error {
+		if err == nil && !info.IsDir() {
+			size += info.Size()
+		}
+		return nil
+	})
+	return size
 }
 
 // SetupFS initializes the workspace with the corresponding git repository.
-func (c *Consumer) SetupFS(workDir, cloneURL string) error {
+func (c *Consumer) SetupFS(ctx context.Context, workDir, cloneURL string) (err error) {
+	ctx, span := tracing.Start(ctx, "SetupFS")
+	defer func() { tracing.End(span, err) }()
+
 	fs := osfs.New(workDir)
 	gitfs, err := fs.Chroot(git.GitDirName)
 	if err != nil {
@@ -66,7 +123,7 @@ func (c *Consumer) SetupFS(workDir, cloneURL string) error {
 		Auth:  c.AuthMethod,
 	}
 
-	_, err = git.Clone(storage, fs, options)
+	_, err = git.CloneContext(ctx, storage, fs, options)
 	if err != nil {
 		return errors.Wrapf(err, "failed to clone repository")
 	}
@@ -81,9 +138,7 @@ func (c *Consumer) FindEffxYAML(workDir string) ([]string, error) {
 		if err != nil {
 			return err
 		} else if !info.IsDir() {
-			fileName := filepath.Base(path)
-
-			if effxYAMLPattern.MatchString(fileName) {
+			if model.IsEffxYAML(path) {
 				files = append(files, strings.TrimPrefix(path, workDir)[1:])
 			}
 		}
@@ -97,45 +152,241 @@ func (c *Consumer) FindEffxYAML(workDir string) ([]string, error) {
 	return files, err
 }
 
-// Consume attempts to index a repository for effx.yaml files
-func (c *Consumer) Consume(log *zap.Logger, repository *model.Repository) (err error) {
-	cloneURL := repository.CloneURL
-	workDir := path.Join(c.ScratchDir, s256(cloneURL))
+// needsCheckout returns true when indexing inspects more than effx.yaml files,
+// so repositories are cloned even when their host can serve files directly.
+func (c *Consumer) needsCheckout() bool {
+	return !c.EffxClient.IsFeatureDisabled(LanguageDetectionFeature) ||
+		!c.EffxClient.IsFeatureDisabled(ServiceDetectionFeature)
+}
 
-	// clean up workspace
-	defer os.RemoveAll(workDir)
+// ReadTree lists the files of a repository through the API of its host.
+func (c *Consumer) ReadTree(ctx context.Context, source model.Source) (tree *model.Tree, err error) {
+	ctx, span := tracing.Start(ctx, "ReadTree")
+	defer func() { tracing.End(span, err) }()
 
-	err = c.SetupFS(workDir, cloneURL)
-	if err != nil {
-		return err
+	return source.Tree(ctx)
+}
+
+// findEffxYAMLInTree returns the effx.yaml files listed in the tree.
+func findEffxYAMLInTree(tree *model.Tree) []string {
+	files := make([]string, 0)
+	for filePath := range tree.Blobs {
+		if model.IsEffxYAML(filePath) {
+			files = append(files, filePath)
+		}
 	}
+	sort.Strings(files)
+	return files
+}
 
-	effxYAML, err := c.FindEffxYAML(workDir)
+// deleteStale removes catalog entries for effx.yaml files synced by a previous
+// run that are no longer present. It returns the files to remember for the next
+// run, which includes stale files that could not be deleted so they are retried.
+func (c *Consumer) deleteStale(
+	ctx context.Context,
+	log *zap.Logger,
+	repository *model.Repository,
+	present []string,
+	outcome *report.Outcome,
+) []string {
+	files := append([]string{}, present...)
+
+	stale, err := c.State.Stale(repository, present)
 	if err != nil {
-		return err
+		log.Warn("skipping deletion of stale effx.yaml files", zap.Error(err))
+		return append(files, stale...)
 	}
 
-	// parse and send to our API
-	for _, effxYAMLFile := range effxYAML {
+	for _, effxYAMLFile := range stale {
+		err := c.EffxClient.Delete(ctx, effx.NewDeleteRequest(repository.CloneURL, effxYAMLFile))
+		if err != nil {
+			log.Error("failed to delete stale effx.yaml file",
+				zap.String("filePath", effxYAMLFile),
+				zap.Error(err))
+			files = append(files, effxYAMLFile)
+			continue
+		}
+
+		log.Info("deleted stale effx.yaml file",
+			zap.String("filePath", effxYAMLFile))
+		outcome.FilesDeleted++
+
+		if c.SyncCache != nil {
+			c.SyncCache.Remove(repository.CloneURL, effxYAMLFile)
+		}
+	}
+
+	return files
+}
 
-		// gets the dir where the effx file is at
-		// for example /src/stuff/effx.yaml -> /src/stuff/
-		effxDir := workDir + "/" + filepath.Dir(effxYAMLFile)
+// useSyncCache returns true when unchanged files should be skipped. Dry runs
+// always write every request, and never cause a later run to skip a file.
+func (c *Consumer) useSyncCache() bool {
+	return c.SyncCache != nil && !c.EffxClient.IsDryRun()
+}
+
+// Consume attempts to index a repository for effx.yaml files. Cancelling the
+// context interrupts indexing.
+func (c *Consumer) Consume(ctx context.Context, log *zap.Logger, repository *model.Repository) (err error) {
+	if c.Mapping != nil {
+		c.Mapping.Apply(repository)
+	}
+
+	// each repository is its own trace, linked to the run it belongs to
+	ctx, span := tracing.Start(ctx, "Consume",
+		trace.WithNewRoot(),
+		trace.WithLinks(trace.Link{SpanContext: trace.SpanContextFromContext(ctx)}),
+		trace.WithAttributes(attribute.String("repository", repository.CloneURL)))
 
-		result, err := metadata.InferMetadata(effxDir)
+	outcome := &report.Outcome{Repository: repository.CloneURL}
+	defer func(start time.Time) {
+		outcome.DurationSeconds = time.Since(start).Seconds()
+		outcome.Status = report.StatusSynced
 		if err != nil {
-			log.Error("failed to infer langugage",
-				zap.String("filPath", effxYAMLFile),
-				zap.Error(err))
+			outcome.Status = report.StatusFailed
+			outcome.Error = err.Error()
+		} else if outcome.FilesFailed > 0 {
+			outcome.Status = report.StatusFailed
+		}
+
+		metrics.RepositoriesIndexed.WithLabelValues(outcome.Status).Inc()
+		if c.Report != nil {
+			c.Report.Record(outcome)
 		}
 
-		body, err := ioutil.ReadFile(path.Join(workDir, effxYAMLFile))
+		span.SetAttributes(
+			attribute.String("status", outcome.Status),
+			attribute.Int("files.found", outcome.FilesFound),
+			attribute.Int("files.synced", outcome.FilesSynced),
+			attribute.Int("files.failed", outcome.FilesFailed),
+			attribute.Int("files.unchanged", outcome.FilesUnchanged))
+		tracing.End(span, err)
+	}(time.Now())
+
+	cloneURL := repository.CloneURL
+	workDir := repository.WorkDir
+
+	// read effx.yaml files through the host when nothing else in the repository is needed
+	var tree *model.Tree
+	if workDir == "" && repository.Source != nil && !c.needsCheckout() {
+		tree, err = c.ReadTree(ctx, repository.Source)
+		if err != nil {
+			log.Warn("failed to read repository through its host, cloning instead", zap.Error(err))
+		}
+	}
+
+	var commit string
+	if workDir == "" && tree == nil {
+		if err = os.MkdirAll(c.ScratchDir, 0755); err != nil {
+			return errors.Wrap(err, "failed to create scratch dir")
+		}
+
+		// unique per consumption, as the same repository may be pushed again while it is being indexed
+		workDir, err = ioutil.TempDir(c.ScratchDir, s256(cloneURL)+"-")
+		if err != nil {
+			return errors.Wrap(err, "failed to create work dir")
+		}
+
+		// clean up workspace
+		defer os.RemoveAll(workDir)
+
+		// the ssh authentication method cannot be used to clone the https url
+		target := cloneURL
+		if c.CloneOverSSH {
+			if repository.SSHURL == "" {
+				return fmt.Errorf("cloning over ssh was requested, but the repository has no ssh url")
+			}
+			target = repository.SSHURL
+		}
+
+		// surface failures to obtain credentials instead of cloning without them
+		if auth, ok := c.AuthMethod.(refresher); ok {
+			if err = auth.Refresh(ctx); err != nil {
+				return err
+			}
+		}
+
+		cloneStart := time.Now()
+		if c.Mirrors != nil {
+			commit, err = c.Mirrors.Checkout(ctx, target, repository.DefaultBranch, workDir, c.AuthMethod)
+		} else {
+			err = c.SetupFS(ctx, workDir, target)
+		}
+		if err != nil {
+			return err
+		}
+
+		// requests sent without credentials fail the clone, even if the remote allowed them
+		if auth, ok := c.AuthMethod.(refresher); ok {
+			if err = auth.Err(); err != nil {
+				return err
+			}
+		}
+		outcome.Cloned = true
+
+		metrics.CloneDuration.Observe(time.Since(cloneStart).Seconds())
+		metrics.CloneBytes.Observe(float64(dirSize(workDir)))
+	}
+
+	var effxYAML []string
+	readFile := func(filePath string) ([]byte, error) {
+		return ioutil.ReadFile(path.Join(workDir, filePath))
+	}
+
+	if tree != nil {
+		commit = tree.Commit
+		effxYAML = findEffxYAMLInTree(tree)
+		readFile = func(filePath string) ([]byte, error) {
+			return repository.Source.ReadBlob(ctx, tree.Blobs[filePath])
+		}
+	} else {
+		if commit == "" {
+			commit = headCommit(workDir)
+		}
+
+		_, findSpan := tracing.Start(ctx, "FindEffxYAML")
+		effxYAML, err = c.FindEffxYAML(workDir)
+		tracing.End(findSpan, err)
+		if err != nil {
+			return err
+		}
+	}
+	outcome.FilesFound = len(effxYAML)
+	metrics.EffxYAMLFilesFound.Add(float64(len(effxYAML)))
+
+	// parse and send to our API
+	for _, effxYAMLFile := range effxYAML {
+		if ctx.Err() != nil {
+			return errors.Wrap(ctx.Err(), "interrupted while syncing effx.yaml files")
+		}
+
+		// languages are inferred from the files next to the effx.yaml, which requires a checkout
+		var result *metadata.Result
+		if tree == nil {
+			// gets the dir where the effx file is at
+			// for example /src/stuff/effx.yaml -> /src/stuff/
+			effxDir := workDir + "/" + filepath.Dir(effxYAMLFile)
+
+			var err error
+			_, inferSpan := tracing.Start(ctx, "InferMetadata",
+				trace.WithAttributes(attribute.String("file", effxYAMLFile)))
+			result, err = metadata.InferMetadata(effxDir)
+			tracing.End(inferSpan, err)
+			if err != nil {
+				log.Error("failed to infer langugage",
+					zap.String("filPath", effxYAMLFile),
+					zap.Error(err))
+			}
+		}
+
+		body, err := readFile(effxYAMLFile)
 		if err != nil {
 			if log != nil {
 				log.Error("failed to read effx.yaml file",
 					zap.String("filPath", effxYAMLFile),
 					zap.Error(err))
 			}
+			outcome.FilesFailed++
 			continue
 		}
 
@@ -165,47 +416,103 @@ func (c *Consumer) Consume(log *zap.Logger, repository *model.Repository) (err e
 		annotations["effx.io/file-path"] = effxYAMLFile
 		annotations["effx.io/inferred-tags"] = strings.Join(inferredTags, ",")
 
-		err = c.EffxClient.Sync(&effx.SyncRequest{
+		syncRequest := &effx.SyncRequest{
 			FileContents: string(body),
 			Tags:         tags,
 			Annotations:  annotations,
-		})
+		}
+
+		hash := syncRequest.Hash()
+		if c.useSyncCache() && c.SyncCache.Unchanged(cloneURL, effxYAMLFile, hash) {
+			log.Debug("skipping unchanged effx.yaml file",
+				zap.String("filePath", effxYAMLFile))
+			outcome.FilesUnchanged++
+			metrics.EffxYAMLFilesUnchanged.Inc()
+			continue
+		}
 
+		err = c.EffxClient.Sync(ctx, syncRequest)
 		if err != nil {
 			if log != nil {
 				log.Error("failed to synx effx.yaml file",
 					zap.String("filPath", effxYAMLFile),
 					zap.Error(err))
 			}
+			outcome.FilesFailed++
 			continue
 		}
 
 		log.Info("successfully updated effx.yaml file",
 			zap.String("filePath", effxYAMLFile))
+		outcome.FilesSynced++
+
+		if c.useSyncCache() {
+			c.SyncCache.Put(cloneURL, effxYAMLFile, hash)
+		}
 	}
 
-	err = c.EffxClient.DetectServices(workDir)
-	if err != nil {
-		log.Error("failed to detect services", zap.Error(err))
+	if tree == nil && !c.EffxClient.IsFeatureDisabled(ServiceDetectionFeature) {
+		err = c.EffxClient.DetectServices(ctx, workDir)
+		if err != nil {
+			log.Error("failed to detect services", zap.Error(err))
+		}
+	}
+
+	if c.State == nil {
+		return nil
+	}
+
+	files := c.deleteStale(ctx, log, repository, effxYAML, outcome)
+
+	// only record repositories where every file was synced, so failures are retried
+	// and dry runs never cause a later run to skip a repository
+	if outcome.FilesFailed == 0 && !c.EffxClient.IsDryRun() {
+		c.State.Record(repository, commit, files)
 	}
 
 	return nil
 }
 
-// Run consumes repositories from the data channel until the program is shutdown.
+// Begin prepares the consumer for a new pass over the repositories.
+func (c *Consumer) Begin() {
+	if c.State != nil {
+		c.State.Begin()
+	}
+}
+
+// Flush writes what was recorded about the consumed repositories to disk, so
+// the state and sync cache are written once for many repositories rather than
+// for each of them.
+func (c *Consumer) Flush() error {
+	var err error
+	if c.State != nil {
+		err = multierr.Append(err, errors.Wrap(c.State.Save(), "failed to save state"))
+	}
+	if c.SyncCache != nil {
+		err = multierr.Append(err, errors.Wrap(c.SyncCache.Save(), "failed to save sync cache"))
+	}
+	return err
+}
+
+// Run consumes repositories from the data channel until it is closed. Failures
+// to consume individual repositories are logged and returned together.
 func (c *Consumer) Run(ctx context.Context, data chan *model.Repository) error {
 	log := logger.MustGetFromContext(ctx)
 
-	for {
-		select {
-		case <-ctx.Done():
-			return nil
-		case repository := <-data:
-			if err := c.Consume(log, repository); err != nil {
-				log.Error("failed to consume repository",
-					zap.String("repository", repository.CloneURL),
-					zap.Error(err))
-			}
+	var errs error
+	for repository := range data {
+		metrics.WorkersBusy.Inc()
+		err := c.Consume(ctx, log, repository)
+		metrics.WorkersBusy.Dec()
+
+		if err != nil {
+			log.Error("failed to consume repository",
+				zap.String("repository", repository.CloneURL),
+				zap.Error(err))
+
+			errs = multierr.Append(errs, errors.Wrapf(err, "failed to consume %s", repository.CloneURL))
 		}
 	}
+
+	return errs
 }
@@ -1,13 +1,29 @@
 package run_test
 
 import (
+	"context"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
 	"os"
 	"path"
+	"strings"
 	"testing"
+	"time"
 
+	"github.com/effxhq/vcs-connect/internal/effx"
+	"github.com/effxhq/vcs-connect/internal/model"
+	"github.com/effxhq/vcs-connect/internal/report"
 	"github.com/effxhq/vcs-connect/internal/run"
+	"github.com/effxhq/vcs-connect/internal/state"
+	"github.com/effxhq/vcs-connect/internal/synccache"
 
 	"github.com/stretchr/testify/require"
+
+	"github.com/urfave/cli/v2"
+
+	"go.uber.org/zap"
 )
 
 func TestConsumer_SetupFS(t *testing.T) {
@@ -17,7 +33,7 @@ func TestConsumer_SetupFS(t *testing.T) {
 	c := &run.Consumer{}
 
 	// TODO: Use self instead of a separate random repo?
-	err := c.SetupFS(tmp, "https://github.com/effxhq/effx-sync-action.git")
+	err := c.SetupFS(context.Background(), tmp, "https://github.com/effxhq/effx-sync-action.git")
 	require.NoError(t, err)
 
 	_, err = os.Stat(path.Join(tmp, "LICENSE"))
@@ -40,3 +56,204 @@ func TestConsumer_FindEffxYAML(t *testing.T) {
 	require.Contains(t, files, "effx.yml")
 	require.Contains(t, files, "prefixed.effx.yaml")
 }
+
+type fakeSource struct {
+	tree  *model.Tree
+	blobs map[string]string
+}
+
+func (s *fakeSource) Tree(ctx context.Context) (*model.Tree, error) {
+	return s.tree, nil
+}
+
+func (s *fakeSource) ReadBlob(ctx context.Context, id string) ([]byte, error) {
+	return []byte(s.blobs[id]), nil
+}
+
+func TestConsumer_ConsumeSource(t *testing.T) {
+	output := t.TempDir()
+
+	effxClient, err := effx.New(&effx.Configuration{
+		Disable:      *cli.NewStringSlice(run.LanguageDetectionFeature, run.ServiceDetectionFeature),
+		DryRun:       true,
+		DryRunOutput: output,
+	})
+	require.NoError(t, err)
+
+	reporter, err := report.New(&report.Configuration{})
+	require.NoError(t, err)
+
+	c := &run.Consumer{
+		EffxClient: effxClient,
+		ScratchDir: t.TempDir(),
+		Report:     reporter,
+	}
+
+	repository := &model.Repository{
+		CloneURL: "https://github.com/effxhq/vcs-connect.git",
+		Source: &fakeSource{
+			tree: &model.Tree{
+				Commit: "abc123",
+				Blobs: map[string]string{
+					"README.md":              "1",
+					"services/api/effx.yaml": "2",
+				},
+			},
+			blobs: map[string]string{
+				"2": "version: effx/v1\n",
+			},
+		},
+	}
+
+	require.NoError(t, c.Consume(context.Background(), zap.NewNop(), repository))
+
+	summary := reporter.Summarize()
+	require.Len(t, summary.Outcomes, 1)
+	require.False(t, summary.Outcomes[0].Cloned)
+	require.Equal(t, 1, summary.FilesSynced)
+
+	_, err = os.Stat(path.Join(output, "github.com", "effxhq", "vcs-connect", "services", "api", "effx.yaml.json"))
+	require.NoError(t, err)
+}
+
+func TestConsumer_ConsumeWorkDir(t *testing.T) {
+	output := t.TempDir()
+
+	effxClient, err := effx.New(&effx.Configuration{
+		Disable:      *cli.NewStringSlice(run.LanguageDetectionFeature, run.ServiceDetectionFeature),
+		DryRun:       true,
+		DryRunOutput: output,
+	})
+	require.NoError(t, err)
+
+	reporter, err := report.New(&report.Configuration{})
+	require.NoError(t, err)
+
+	c := &run.Consumer{
+		EffxClient: effxClient,
+		ScratchDir: t.TempDir(),
+		Report:     reporter,
+	}
+
+	workDir := t.TempDir()
+	require.NoError(t, ioutil.WriteFile(path.Join(workDir, "effx.yaml"), []byte("version: effx/v1\n"), 0644))
+
+	require.NoError(t, c.Consume(context.Background(), zap.NewNop(), &model.Repository{
+		CloneURL: "https://github.com/effxhq/vcs-connect.git",
+		WorkDir:  workDir,
+	}))
+
+	// existing checkouts are indexed in place, and left behind
+	summary := reporter.Summarize()
+	require.Len(t, summary.Outcomes, 1)
+	require.False(t, summary.Outcomes[0].Cloned)
+	require.Equal(t, 1, summary.FilesSynced)
+
+	_, err = os.Stat(path.Join(workDir, "effx.yaml"))
+	require.NoError(t, err)
+
+	_, err = os.Stat(path.Join(output, "github.com", "effxhq", "vcs-connect", "effx.yaml.json"))
+	require.NoError(t, err)
+}
+
+type expiredAuth struct{}
+
+func (a *expiredAuth) Name() string                      { return "expired" }
+func (a *expiredAuth) String() string                    { return "expired" }
+func (a *expiredAuth) Refresh(ctx context.Context) error { return fmt.Errorf("token expired") }
+func (a *expiredAuth) Err() error                        { return fmt.Errorf("token expired") }
+
+func TestConsumer_ConsumeRefreshError(t *testing.T) {
+	effxClient, err := effx.New(&effx.Configuration{
+		DryRun:       true,
+		DryRunOutput: t.TempDir(),
+	})
+	require.NoError(t, err)
+
+	c := &run.Consumer{
+		EffxClient: effxClient,
+		ScratchDir: t.TempDir(),
+		AuthMethod: &expiredAuth{},
+	}
+
+	err = c.Consume(context.Background(), zap.NewNop(), &model.Repository{
+		CloneURL: "https://github.com/effxhq/vcs-connect.git",
+	})
+	require.EqualError(t, err, "token expired")
+}
+
+func TestConsumer_ConsumeMissingSSHURL(t *testing.T) {
+	effxClient, err := effx.New(&effx.Configuration{
+		DryRun:       true,
+		DryRunOutput: t.TempDir(),
+	})
+	require.NoError(t, err)
+
+	c := &run.Consumer{
+		EffxClient:   effxClient,
+		ScratchDir:   t.TempDir(),
+		CloneOverSSH: true,
+	}
+
+	err = c.Consume(context.Background(), zap.NewNop(), &model.Repository{
+		CloneURL: "https://github.com/effxhq/vcs-connect.git",
+	})
+	require.EqualError(t, err, "cloning over ssh was requested, but the repository has no ssh url")
+}
+
+func TestConsumer_Flush(t *testing.T) {
+	server := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	defer server.Close()
+
+	// requests are sent with the default client, which must trust the test server
+	defaultClient := http.DefaultClient
+	http.DefaultClient = server.Client()
+	defer func() { http.DefaultClient = defaultClient }()
+
+	effxClient, err := effx.New(&effx.Configuration{
+		APIKey:  "api_key",
+		APIHost: strings.TrimPrefix(server.URL, "https://"),
+		Disable: *cli.NewStringSlice(run.LanguageDetectionFeature, run.ServiceDetectionFeature),
+	})
+	require.NoError(t, err)
+
+	dir := t.TempDir()
+
+	store, err := state.Open(&state.Configuration{File: path.Join(dir, "state.json")})
+	require.NoError(t, err)
+
+	syncCache, err := synccache.Open(&synccache.Configuration{File: path.Join(dir, "sync-cache.json"), TTL: time.Hour})
+	require.NoError(t, err)
+
+	c := &run.Consumer{
+		EffxClient: effxClient,
+		ScratchDir: t.TempDir(),
+		State:      store,
+		SyncCache:  syncCache,
+	}
+
+	for _, name := range []string{"vcs-connect", "other"} {
+		require.NoError(t, c.Consume(context.Background(), zap.NewNop(), &model.Repository{
+			CloneURL: "https://github.com/effxhq/" + name + ".git",
+			Source: &fakeSource{
+				tree:  &model.Tree{Commit: "abc123", Blobs: map[string]string{"effx.yaml": "1"}},
+				blobs: map[string]string{"1": "version: effx/v1\n"},
+			},
+		}))
+	}
+
+	// nothing is written until the consumer is flushed
+	for _, file := range []string{"state.json", "sync-cache.json"} {
+		_, err = os.Stat(path.Join(dir, file))
+		require.True(t, os.IsNotExist(err))
+	}
+
+	require.NoError(t, c.Flush())
+
+	for _, file := range []string{"state.json", "sync-cache.json"} {
+		_, err = os.Stat(path.Join(dir, file))
+		require.NoError(t, err)
+	}
+}
@@ -2,3 +2,6 @@ package run
 
 // LanguageDetectionFeature is a feature to detect languages, and versions.
 const LanguageDetectionFeature = "LANGUAGE_DETECTION"
+
+// ServiceDetectionFeature is a feature to detect services in repositories without effx.yaml files.
+const ServiceDetectionFeature = "SERVICE_DETECTION"
@@ -0,0 +1,43 @@
+package sshauth
+
+import (
+	"github.com/pkg/errors"
+
+	"gopkg.in/src-d/go-git.v4/plumbing/transport"
+	"gopkg.in/src-d/go-git.v4/plumbing/transport/ssh"
+)
+
+// New returns the authentication used to clone repositories over SSH. A private key
+// is used when configured, otherwise keys are requested from the running ssh-agent.
+// Hosts are verified against the configured known_hosts file.
+func New(cfg *Configuration) (transport.AuthMethod, error) {
+	if err := cfg.Validate(); err != nil {
+		return nil, err
+	}
+
+	knownHosts := make([]string, 0)
+	if cfg.KnownHostsFile != "" {
+		knownHosts = append(knownHosts, cfg.KnownHostsFile)
+	}
+
+	hostKeyCallback, err := ssh.NewKnownHostsCallback(knownHosts...)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to load known hosts")
+	}
+
+	if cfg.PrivateKeyFile != "" {
+		auth, err := ssh.NewPublicKeysFromFile(cfg.User, cfg.PrivateKeyFile, cfg.PrivateKeyPassword)
+		if err != nil {
+			return nil, errors.Wrap(err, "failed to load private key")
+		}
+		auth.HostKeyCallback = hostKeyCallback
+		return auth, nil
+	}
+
+	auth, err := ssh.NewSSHAgentAuth(cfg.User)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to connect to ssh-agent")
+	}
+	auth.HostKeyCallback = hostKeyCallback
+	return auth, nil
+}
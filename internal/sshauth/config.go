@@ -0,0 +1,73 @@
+package sshauth
+
+import (
+	"fmt"
+
+	"github.com/urfave/cli/v2"
+)
+
+// Configuration encapsulates information needed for cloning repositories over SSH.
+type Configuration struct {
+	Enabled            bool
+	User               string
+	PrivateKeyFile     string
+	PrivateKeyPassword string
+	KnownHostsFile     string
+}
+
+// Validate ensures the configuration provided contains the required information.
+func (c *Configuration) Validate() error {
+	if !c.Enabled {
+		return nil
+	} else if c.User == "" {
+		return fmt.Errorf("an ssh user must be provided")
+	}
+	return nil
+}
+
+// DefaultConfigWithFlags returns configuration and flags specific to cloning over SSH.
+func DefaultConfigWithFlags() (*Configuration, []cli.Flag) {
+	cfg := &Configuration{
+		User: "git",
+	}
+
+	flags := []cli.Flag{
+		&cli.BoolFlag{
+			Name:        "clone-over-ssh",
+			Usage:       "clone repositories using their ssh url instead of https",
+			Destination: &(cfg.Enabled),
+			Value:       cfg.Enabled,
+			EnvVars:     []string{"CLONE_OVER_SSH"},
+		},
+		&cli.StringFlag{
+			Name:        "ssh-user",
+			Usage:       "the user used when connecting over ssh",
+			Destination: &(cfg.User),
+			Value:       cfg.User,
+			EnvVars:     []string{"SSH_USER"},
+		},
+		&cli.StringFlag{
+			Name:        "ssh-private-key-file",
+			Usage:       "path to the private key (or deploy key) used to clone, ssh-agent is used when omitted",
+			Destination: &(cfg.PrivateKeyFile),
+			Value:       cfg.PrivateKeyFile,
+			EnvVars:     []string{"SSH_PRIVATE_KEY_FILE"},
+		},
+		&cli.StringFlag{
+			Name:        "ssh-private-key-password",
+			Usage:       "password used to decrypt the private key",
+			Destination: &(cfg.PrivateKeyPassword),
+			Value:       cfg.PrivateKeyPassword,
+			EnvVars:     []string{"SSH_PRIVATE_KEY_PASSWORD"},
+		},
+		&cli.StringFlag{
+			Name:        "ssh-known-hosts-file",
+			Usage:       "known_hosts file used to verify hosts, defaults to SSH_KNOWN_HOSTS or ~/.ssh/known_hosts",
+			Destination: &(cfg.KnownHostsFile),
+			Value:       cfg.KnownHostsFile,
+			EnvVars:     []string{"SSH_KNOWN_HOSTS_FILE"},
+		},
+	}
+
+	return cfg, flags
+}
@@ -0,0 +1,79 @@
+package state
+
+import (
+	"fmt"
+
+	"github.com/urfave/cli/v2"
+)
+
+// Configuration encapsulates information needed for tracking what was indexed
+// by previous runs.
+type Configuration struct {
+	File       string
+	FullResync bool
+	// DeleteStale removes catalog entries for effx.yaml files that disappeared since the last run.
+	DeleteStale     bool
+	DeleteThreshold float64
+	// DeleteLimit bounds how many files are deleted in a single pass, 0 removes the bound.
+	DeleteLimit int
+}
+
+// Validate ensures the configuration provided contains the required information.
+func (c *Configuration) Validate() error {
+	if c.File == "" {
+		return fmt.Errorf("a state file must be provided")
+	} else if c.DeleteThreshold < 0 || c.DeleteThreshold > 1 {
+		return fmt.Errorf("the delete threshold must be between 0 and 1")
+	} else if c.DeleteLimit < 0 {
+		return fmt.Errorf("the delete limit must not be negative")
+	}
+	return nil
+}
+
+// DefaultConfigWithFlags returns configuration and flags specific to the state store.
+func DefaultConfigWithFlags() (*Configuration, []cli.Flag) {
+	cfg := &Configuration{
+		DeleteThreshold: 0.5,
+		DeleteLimit:     50,
+	}
+
+	flags := []cli.Flag{
+		&cli.StringFlag{
+			Name:        "state-file",
+			Usage:       "file recording what previous runs indexed, defaults to state.json in the scratch dir",
+			Destination: &(cfg.File),
+			Value:       cfg.File,
+			EnvVars:     []string{"STATE_FILE"},
+		},
+		&cli.BoolFlag{
+			Name:        "full-resync",
+			Usage:       "index every repository, even those unchanged since the previous run",
+			Destination: &(cfg.FullResync),
+			Value:       cfg.FullResync,
+			EnvVars:     []string{"FULL_RESYNC"},
+		},
+		&cli.BoolFlag{
+			Name:        "delete-stale",
+			Usage:       "delete catalog entries for effx.yaml files removed since the previous run",
+			Destination: &(cfg.DeleteStale),
+			Value:       cfg.DeleteStale,
+			EnvVars:     []string{"DELETE_STALE"},
+		},
+		&cli.Float64Flag{
+			Name:        "delete-threshold",
+			Usage:       "fraction of a repository's effx.yaml files that may be deleted at once",
+			Destination: &(cfg.DeleteThreshold),
+			Value:       cfg.DeleteThreshold,
+			EnvVars:     []string{"DELETE_THRESHOLD"},
+		},
+		&cli.IntFlag{
+			Name:        "delete-limit",
+			Usage:       "maximum number of effx.yaml files deleted in a single pass, 0 for no limit",
+			Destination: &(cfg.DeleteLimit),
+			Value:       cfg.DeleteLimit,
+			EnvVars:     []string{"DELETE_LIMIT"},
+		},
+	}
+
+	return cfg, flags
+}
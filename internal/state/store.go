@@ -0,0 +1,207 @@
+package state
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/effxhq/vcs-connect/internal/model"
+
+	"github.com/pkg/errors"
+)
+
+// activityWindow bounds how long a host may take to report a push. GitLab only
+// updates the last activity of a project once an hour, so pushes shortly after
+// the reported time may not change it.
+const activityWindow = time.Hour
+
+// Repository records the outcome of the last successful indexing of a repository.
+type Repository struct {
+	Commit    string    `json:"commit,omitempty"`
+	PushedAt  time.Time `json:"pushedAt,omitempty"`
+	IndexedAt time.Time `json:"indexedAt"`
+	// Files lists the effx.yaml files synced from the repository.
+	Files []string `json:"files,omitempty"`
+}
+
+// Open loads the store from the configured file. A missing file results in an empty store.
+func Open(cfg *Configuration) (*Store, error) {
+	if err := cfg.Validate(); err != nil {
+		return nil, err
+	}
+
+	store := &Store{
+		cfg:          cfg,
+		repositories: make(map[string]*Repository),
+	}
+
+	body, err := ioutil.ReadFile(cfg.File)
+	if os.IsNotExist(err) {
+		return store, nil
+	} else if err != nil {
+		return nil, errors.Wrap(err, "failed to read state file")
+	}
+
+	if err := json.Unmarshal(body, &store.repositories); err != nil {
+		return nil, errors.Wrap(err, "failed to parse state file")
+	}
+
+	return store, nil
+}
+
+// Store persists what was indexed per repository so later runs can skip
+// repositories that have not changed. It is safe for concurrent use.
+type Store struct {
+	cfg *Configuration
+
+	mu           sync.Mutex
+	repositories map[string]*Repository
+	dirty        bool
+	// deleted counts the files returned by Stale since the pass began
+	deleted int
+}
+
+// Begin starts a new pass, resetting the number of files that may be deleted.
+func (s *Store) Begin() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.deleted = 0
+}
+
+// Get returns the recorded state of the repository, or nil if it was never indexed.
+func (s *Store) Get(repository *model.Repository) *Repository {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return s.repositories[repository.CloneURL]
+}
+
+// Reason returns why the repository can be skipped, or an empty string when it should be indexed.
+// Repositories are skipped when the host reports no pushes since they were last indexed, and
+// they were indexed long enough after the reported push that later pushes would have been
+// reported as well.
+func (s *Store) Reason(repository *model.Repository) string {
+	if s.cfg.FullResync || repository.PushedAt.IsZero() {
+		return ""
+	}
+
+	previous := s.Get(repository)
+	if previous == nil || !previous.PushedAt.Equal(repository.PushedAt) {
+		return ""
+	} else if previous.IndexedAt.Before(repository.PushedAt.Add(activityWindow)) {
+		return ""
+	}
+	return "repository unchanged since last run"
+}
+
+// Stale returns the effx.yaml files synced by a previous run that are no longer
+// present in the repository. Nothing is returned unless deletion is enabled, and an
+// error is returned when more files disappeared than the delete threshold allows,
+// or when deleting them would exceed the files that may be deleted in this pass.
+func (s *Store) Stale(repository *model.Repository, present []string) ([]string, error) {
+	if !s.cfg.DeleteStale {
+		return nil, nil
+	}
+
+	previous := s.Get(repository)
+	if previous == nil {
+		return nil, nil
+	}
+
+	found := make(map[string]bool, len(present))
+	for _, file := range present {
+		found[file] = true
+	}
+
+	stale := make([]string, 0)
+	for _, file := range previous.Files {
+		if !found[file] {
+			stale = append(stale, file)
+		}
+	}
+
+	// a single file may always be removed, otherwise repositories with one file could never delete it
+	if len(stale) > 1 && float64(len(stale)) > s.cfg.DeleteThreshold*float64(len(previous.Files)) {
+		return stale, fmt.Errorf("%d of %d effx.yaml files disappeared, exceeding the delete threshold",
+			len(stale), len(previous.Files))
+	}
+
+	if len(stale) == 0 {
+		return stale, nil
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	// many repositories each losing a file at once is as suspicious as a single repository losing many
+	if s.cfg.DeleteLimit > 0 && s.deleted+len(stale) > s.cfg.DeleteLimit {
+		return stale, fmt.Errorf("deleting %d effx.yaml files would exceed the limit of %d per pass",
+			len(stale), s.cfg.DeleteLimit)
+	}
+	s.deleted += len(stale)
+
+	return stale, nil
+}
+
+// Record marks the repository as successfully indexed at the provided commit,
+// along with the effx.yaml files synced from it. Changes are kept in memory until
+// the store is saved.
+func (s *Store) Record(repository *model.Repository, commit string, files []string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.repositories[repository.CloneURL] = &Repository{
+		Commit:    commit,
+		PushedAt:  repository.PushedAt,
+		IndexedAt: time.Now().UTC(),
+		Files:     files,
+	}
+	s.dirty = true
+}
+
+// Save writes the store to disk when it changed. The store is written to a
+// temporary file before moving it into place, so a crash never leaves a partially
+// written state file behind.
+func (s *Store) Save() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if !s.dirty {
+		return nil
+	}
+
+	body, err := json.Marshal(s.repositories)
+	if err != nil {
+		return err
+	}
+
+	dir := filepath.Dir(s.cfg.File)
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return errors.Wrap(err, "failed to create state directory")
+	}
+
+	tmp, err := ioutil.TempFile(dir, ".state-*")
+	if err != nil {
+		return errors.Wrap(err, "failed to create state file")
+	}
+	defer os.Remove(tmp.Name())
+
+	if _, err := tmp.Write(body); err != nil {
+		tmp.Close()
+		return errors.Wrap(err, "failed to write state file")
+	} else if err := tmp.Close(); err != nil {
+		return errors.Wrap(err, "failed to write state file")
+	}
+
+	if err := os.Rename(tmp.Name(), s.cfg.File); err != nil {
+		return errors.Wrap(err, "failed to write state file")
+	}
+
+	s.dirty = false
+	return nil
+}
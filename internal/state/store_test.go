@@ -0,0 +1,145 @@
+package state_test
+
+import (
+	"fmt"
+	"os"
+	"path"
+	"testing"
+	"time"
+
+	"github.com/effxhq/vcs-connect/internal/model"
+	"github.com/effxhq/vcs-connect/internal/state"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestStore(t *testing.T) {
+	cfg := &state.Configuration{
+		File: path.Join(t.TempDir(), "nested", "state.json"),
+	}
+
+	pushedAt := time.Date(2021, 3, 1, 12, 0, 0, 0, time.UTC)
+	repository := &model.Repository{
+		CloneURL: "https://github.com/effxhq/vcs-connect.git",
+		PushedAt: pushedAt,
+	}
+
+	store, err := state.Open(cfg)
+	require.NoError(t, err)
+	require.Nil(t, store.Get(repository))
+	require.Empty(t, store.Reason(repository))
+
+	store.Record(repository, "abc123", []string{"effx.yaml"})
+
+	// nothing is written until the store is saved
+	_, err = os.Stat(cfg.File)
+	require.True(t, os.IsNotExist(err))
+	require.NoError(t, store.Save())
+
+	// reload from disk
+	store, err = state.Open(cfg)
+	require.NoError(t, err)
+	require.Equal(t, "abc123", store.Get(repository).Commit)
+	require.NotEmpty(t, store.Reason(repository))
+
+	// new pushes are indexed
+	require.Empty(t, store.Reason(&model.Repository{
+		CloneURL: repository.CloneURL,
+		PushedAt: pushedAt.Add(time.Minute),
+	}))
+
+	// hosts without push times are always indexed
+	require.Empty(t, store.Reason(&model.Repository{
+		CloneURL: repository.CloneURL,
+	}))
+
+	cfg.FullResync = true
+	require.Empty(t, store.Reason(repository))
+}
+
+func TestStore_RecentPush(t *testing.T) {
+	store, err := state.Open(&state.Configuration{
+		File: path.Join(t.TempDir(), "state.json"),
+	})
+	require.NoError(t, err)
+
+	// hosts may not report pushes made shortly after the reported one, so
+	// repositories indexed soon after a push are indexed again
+	repository := &model.Repository{
+		CloneURL: "https://gitlab.com/effxhq/vcs-connect.git",
+		PushedAt: time.Now().Add(-10 * time.Minute),
+	}
+	store.Record(repository, "abc123", []string{"effx.yaml"})
+	require.Empty(t, store.Reason(repository))
+
+	// until they were indexed long enough after it
+	repository.PushedAt = time.Now().Add(-2 * time.Hour)
+	store.Record(repository, "abc123", []string{"effx.yaml"})
+	require.NotEmpty(t, store.Reason(repository))
+}
+
+func TestStore_Stale(t *testing.T) {
+	cfg := &state.Configuration{
+		File:            path.Join(t.TempDir(), "state.json"),
+		DeleteThreshold: 0.5,
+	}
+
+	repository := &model.Repository{CloneURL: "https://github.com/effxhq/vcs-connect.git"}
+
+	store, err := state.Open(cfg)
+	require.NoError(t, err)
+	store.Record(repository, "abc123", []string{"a/effx.yaml", "b/effx.yaml", "c/effx.yaml", "d/effx.yaml"})
+
+	// disabled by default
+	stale, err := store.Stale(repository, []string{"a/effx.yaml"})
+	require.NoError(t, err)
+	require.Empty(t, stale)
+
+	cfg.DeleteStale = true
+
+	stale, err = store.Stale(repository, []string{"a/effx.yaml", "b/effx.yaml", "moved/effx.yaml"})
+	require.NoError(t, err)
+	require.ElementsMatch(t, []string{"c/effx.yaml", "d/effx.yaml"}, stale)
+
+	// more than half of the files disappearing is suspicious
+	_, err = store.Stale(repository, []string{})
+	require.Error(t, err)
+
+	// repositories never indexed have nothing to delete
+	stale, err = store.Stale(&model.Repository{CloneURL: "https://github.com/effxhq/other.git"}, nil)
+	require.NoError(t, err)
+	require.Empty(t, stale)
+}
+
+func TestStore_StaleLimit(t *testing.T) {
+	cfg := &state.Configuration{
+		File:        path.Join(t.TempDir(), "state.json"),
+		DeleteStale: true,
+		DeleteLimit: 2,
+	}
+
+	store, err := state.Open(cfg)
+	require.NoError(t, err)
+
+	repositories := make([]*model.Repository, 3)
+	for i := range repositories {
+		repositories[i] = &model.Repository{CloneURL: fmt.Sprintf("https://github.com/effxhq/repo-%d.git", i)}
+		store.Record(repositories[i], "abc123", []string{"effx.yaml"})
+	}
+
+	for _, repository := range repositories[:2] {
+		stale, err := store.Stale(repository, nil)
+		require.NoError(t, err)
+		require.Equal(t, []string{"effx.yaml"}, stale)
+	}
+
+	// the single file of each repository is allowed, but not across more repositories than the limit
+	_, err = store.Stale(repositories[2], nil)
+	require.Error(t, err)
+
+	// the limit applies to each pass
+	store.Begin()
+	stale, err := store.Stale(repositories[2], nil)
+	require.NoError(t, err)
+	require.Equal(t, []string{"effx.yaml"}, stale)
+}
@@ -0,0 +1,158 @@
+package synccache
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+// Entry records the hash of the request an effx.yaml file was last synced with.
+type Entry struct {
+	Hash     string    `json:"hash"`
+	SyncedAt time.Time `json:"syncedAt"`
+}
+
+// Open loads the cache from the configured file. A missing file results in an empty cache.
+func Open(cfg *Configuration) (*Cache, error) {
+	if err := cfg.Validate(); err != nil {
+		return nil, err
+	}
+
+	cache := &Cache{
+		cfg:     cfg,
+		entries: make(map[string]map[string]*Entry),
+	}
+
+	if !cfg.Enabled() {
+		return cache, nil
+	}
+
+	body, err := ioutil.ReadFile(cfg.File)
+	if os.IsNotExist(err) {
+		return cache, nil
+	} else if err != nil {
+		return nil, errors.Wrap(err, "failed to read sync cache file")
+	}
+
+	if err := json.Unmarshal(body, &cache.entries); err != nil {
+		return nil, errors.Wrap(err, "failed to parse sync cache file")
+	}
+
+	return cache, nil
+}
+
+// Cache remembers what each effx.yaml file was last synced with, keyed by
+// repository and file path, so identical requests are not sent again until the
+// entry expires. It is safe for concurrent use.
+type Cache struct {
+	cfg *Configuration
+
+	mu      sync.Mutex
+	entries map[string]map[string]*Entry
+	dirty   bool
+}
+
+func (c *Cache) expired(entry *Entry, now time.Time) bool {
+	return now.Sub(entry.SyncedAt) >= c.cfg.TTL
+}
+
+// Unchanged returns true when the file was synced with the same hash within the ttl.
+func (c *Cache) Unchanged(repository, filePath, hash string) bool {
+	if !c.cfg.Enabled() {
+		return false
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	entry := c.entries[repository][filePath]
+	return entry != nil && entry.Hash == hash && !c.expired(entry, time.Now())
+}
+
+// Put records that the file was synced with the provided hash.
+func (c *Cache) Put(repository, filePath, hash string) {
+	if !c.cfg.Enabled() {
+		return
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	files := c.entries[repository]
+	if files == nil {
+		files = make(map[string]*Entry)
+		c.entries[repository] = files
+	}
+
+	files[filePath] = &Entry{Hash: hash, SyncedAt: time.Now().UTC()}
+	c.dirty = true
+}
+
+// Remove forgets the file, so it is synced again should it reappear.
+func (c *Cache) Remove(repository, filePath string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if _, ok := c.entries[repository][filePath]; ok {
+		delete(c.entries[repository], filePath)
+		c.dirty = true
+	}
+}
+
+// Save writes the cache to disk when it changed, dropping expired entries.
+func (c *Cache) Save() error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if !c.dirty {
+		return nil
+	}
+
+	now := time.Now()
+	for repository, files := range c.entries {
+		for filePath, entry := range files {
+			if c.expired(entry, now) {
+				delete(files, filePath)
+			}
+		}
+		if len(files) == 0 {
+			delete(c.entries, repository)
+		}
+	}
+
+	body, err := json.Marshal(c.entries)
+	if err != nil {
+		return err
+	}
+
+	dir := filepath.Dir(c.cfg.File)
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return errors.Wrap(err, "failed to create sync cache directory")
+	}
+
+	// written to a temporary file first, so a crash never leaves a partial cache behind
+	tmp, err := ioutil.TempFile(dir, ".sync-cache-*")
+	if err != nil {
+		return errors.Wrap(err, "failed to create sync cache file")
+	}
+	defer os.Remove(tmp.Name())
+
+	if _, err := tmp.Write(body); err != nil {
+		tmp.Close()
+		return errors.Wrap(err, "failed to write sync cache file")
+	} else if err := tmp.Close(); err != nil {
+		return errors.Wrap(err, "failed to write sync cache file")
+	}
+
+	if err := os.Rename(tmp.Name(), c.cfg.File); err != nil {
+		return errors.Wrap(err, "failed to write sync cache file")
+	}
+
+	c.dirty = false
+	return nil
+}
@@ -0,0 +1,48 @@
+package synccache_test
+
+import (
+	"path"
+	"testing"
+	"time"
+
+	"github.com/effxhq/vcs-connect/internal/synccache"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestCache(t *testing.T) {
+	cfg := &synccache.Configuration{
+		File: path.Join(t.TempDir(), "nested", "sync-cache.json"),
+		TTL:  time.Hour,
+	}
+
+	repository := "https://github.com/effxhq/vcs-connect.git"
+
+	cache, err := synccache.Open(cfg)
+	require.NoError(t, err)
+	require.False(t, cache.Unchanged(repository, "effx.yaml", "abc"))
+
+	cache.Put(repository, "effx.yaml", "abc")
+	require.NoError(t, cache.Save())
+
+	// reload from disk
+	cache, err = synccache.Open(cfg)
+	require.NoError(t, err)
+	require.True(t, cache.Unchanged(repository, "effx.yaml", "abc"))
+	require.False(t, cache.Unchanged(repository, "effx.yaml", "def"))
+	require.False(t, cache.Unchanged(repository, "other/effx.yaml", "abc"))
+
+	cache.Remove(repository, "effx.yaml")
+	require.False(t, cache.Unchanged(repository, "effx.yaml", "abc"))
+
+	// entries expire once the ttl elapses
+	cache.Put(repository, "effx.yaml", "abc")
+	cfg.TTL = time.Nanosecond
+	time.Sleep(time.Millisecond)
+	require.False(t, cache.Unchanged(repository, "effx.yaml", "abc"))
+
+	// a zero ttl disables the cache
+	cfg.TTL = 0
+	cache.Put(repository, "effx.yaml", "abc")
+	require.False(t, cache.Unchanged(repository, "effx.yaml", "abc"))
+}
@@ -0,0 +1,55 @@
+package synccache
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/urfave/cli/v2"
+)
+
+// Configuration encapsulates information needed for skipping effx.yaml files
+// that have not changed since they were last synced.
+type Configuration struct {
+	File string
+	// TTL is how long a synced file may be skipped before it is synced again. Zero disables the cache.
+	TTL time.Duration
+}
+
+// Enabled returns true when unchanged files should be skipped.
+func (c *Configuration) Enabled() bool {
+	return c.TTL > 0
+}
+
+// Validate ensures the configuration provided contains the required information.
+func (c *Configuration) Validate() error {
+	if c.TTL < 0 {
+		return fmt.Errorf("the sync cache ttl must not be negative")
+	} else if c.Enabled() && c.File == "" {
+		return fmt.Errorf("a sync cache file must be provided")
+	}
+	return nil
+}
+
+// DefaultConfigWithFlags returns configuration and flags specific to the sync cache.
+func DefaultConfigWithFlags() (*Configuration, []cli.Flag) {
+	cfg := &Configuration{}
+
+	flags := []cli.Flag{
+		&cli.StringFlag{
+			Name:        "sync-cache-file",
+			Usage:       "file recording the contents of synced effx.yaml files, defaults to sync-cache.json in the scratch dir",
+			Destination: &(cfg.File),
+			Value:       cfg.File,
+			EnvVars:     []string{"SYNC_CACHE_FILE"},
+		},
+		&cli.DurationFlag{
+			Name:        "sync-cache-ttl",
+			Usage:       "how long unchanged effx.yaml files are skipped before they are synced again, skipping is disabled when omitted",
+			Destination: &(cfg.TTL),
+			Value:       cfg.TTL,
+			EnvVars:     []string{"SYNC_CACHE_TTL"},
+		},
+	}
+
+	return cfg, flags
+}
@@ -0,0 +1,90 @@
+package tracing
+
+import (
+	"fmt"
+
+	"github.com/urfave/cli/v2"
+)
+
+const (
+	// ExporterNone disables tracing.
+	ExporterNone = "none"
+	// ExporterOTLP sends spans to an OpenTelemetry collector over gRPC.
+	ExporterOTLP = "otlp"
+	// ExporterStdout writes spans to stdout as JSON.
+	ExporterStdout = "stdout"
+	// ExporterFile writes spans to a file as JSON.
+	ExporterFile = "file"
+)
+
+// Configuration encapsulates information needed for exporting traces.
+type Configuration struct {
+	Exporter     string
+	OTLPEndpoint string
+	OTLPInsecure bool
+	File         string
+	ServiceName  string
+}
+
+// Validate ensures the configuration provided contains the required information.
+func (c *Configuration) Validate() error {
+	switch c.Exporter {
+	case ExporterNone, ExporterOTLP, ExporterStdout:
+		return nil
+	case ExporterFile:
+		if c.File == "" {
+			return fmt.Errorf("a file must be provided for the file exporter")
+		}
+		return nil
+	default:
+		return fmt.Errorf("unsupported tracing exporter %s", c.Exporter)
+	}
+}
+
+// DefaultConfigWithFlags returns configuration and flags specific to tracing.
+func DefaultConfigWithFlags() (*Configuration, []cli.Flag) {
+	cfg := &Configuration{
+		Exporter:    ExporterNone,
+		ServiceName: "vcs-connect",
+	}
+
+	flags := []cli.Flag{
+		&cli.StringFlag{
+			Name:        "tracing-exporter",
+			Usage:       "where spans are exported: none, otlp, stdout or file",
+			Destination: &(cfg.Exporter),
+			Value:       cfg.Exporter,
+			EnvVars:     []string{"TRACING_EXPORTER"},
+		},
+		&cli.StringFlag{
+			Name:        "tracing-otlp-endpoint",
+			Usage:       "host and port of the OTLP gRPC receiver, defaults to OTEL_EXPORTER_OTLP_ENDPOINT or localhost:4317",
+			Destination: &(cfg.OTLPEndpoint),
+			Value:       cfg.OTLPEndpoint,
+			EnvVars:     []string{"TRACING_OTLP_ENDPOINT"},
+		},
+		&cli.BoolFlag{
+			Name:        "tracing-otlp-insecure",
+			Usage:       "disables TLS when connecting to the OTLP receiver",
+			Destination: &(cfg.OTLPInsecure),
+			Value:       cfg.OTLPInsecure,
+			EnvVars:     []string{"TRACING_OTLP_INSECURE"},
+		},
+		&cli.StringFlag{
+			Name:        "tracing-file",
+			Usage:       "file spans are written to when using the file exporter",
+			Destination: &(cfg.File),
+			Value:       cfg.File,
+			EnvVars:     []string{"TRACING_FILE"},
+		},
+		&cli.StringFlag{
+			Name:        "tracing-service-name",
+			Usage:       "service name reported with every span",
+			Destination: &(cfg.ServiceName),
+			Value:       cfg.ServiceName,
+			EnvVars:     []string{"TRACING_SERVICE_NAME"},
+		},
+	}
+
+	return cfg, flags
+}
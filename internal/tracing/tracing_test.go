@@ -0,0 +1,41 @@
+package tracing_test
+
+import (
+	"context"
+	"io/ioutil"
+	"path"
+	"testing"
+
+	"github.com/effxhq/vcs-connect/internal/tracing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestSetup_File(t *testing.T) {
+	file := path.Join(t.TempDir(), "traces.json")
+
+	shutdown, err := tracing.Setup(context.Background(), &tracing.Configuration{
+		Exporter:    tracing.ExporterFile,
+		File:        file,
+		ServiceName: "vcs-connect",
+	})
+	require.NoError(t, err)
+
+	ctx, parent := tracing.Start(context.Background(), "Run")
+	_, child := tracing.Start(ctx, "Consume")
+	tracing.End(child, nil)
+	tracing.End(parent, nil)
+
+	require.NoError(t, shutdown(context.Background()))
+
+	body, err := ioutil.ReadFile(file)
+	require.NoError(t, err)
+	require.Contains(t, string(body), `"Name":"Run"`)
+	require.Contains(t, string(body), `"Name":"Consume"`)
+}
+
+func TestConfiguration_Validate(t *testing.T) {
+	require.NoError(t, (&tracing.Configuration{Exporter: tracing.ExporterNone}).Validate())
+	require.Error(t, (&tracing.Configuration{Exporter: tracing.ExporterFile}).Validate())
+	require.Error(t, (&tracing.Configuration{Exporter: "zipkin"}).Validate())
+}